@@ -0,0 +1,78 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Comparisons supported on a log's attributes
+const (
+	ATTRIBUTE_EQ     = "eq"
+	ATTRIBUTE_EXISTS = "exists"
+	ATTRIBUTE_GT     = "gt"
+	ATTRIBUTE_GTE    = "gte"
+	ATTRIBUTE_LT     = "lt"
+	ATTRIBUTE_LTE    = "lte"
+)
+
+var ATTRIBUTE_NUMERIC_OPERATORS = map[string]string{
+	ATTRIBUTE_GT:  ">",
+	ATTRIBUTE_GTE: ">=",
+	ATTRIBUTE_LT:  "<",
+	ATTRIBUTE_LTE: "<=",
+}
+
+// Matches logs on a top level attribute key, eg {"key": "duration_ms", "op": "gte", "value": 500}.
+// Equality compares JSON values so 42 and "42" are different, existence ignores Value.
+type AttributeFilter struct {
+	Key   string `json:"key"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+// Returns the SQL condition for the filter, adding its arguments to args
+func (f AttributeFilter) condition(args *queryArgs) (string, error) {
+	if f.Key == "" {
+		return "", errors.New(error_msgs.GetRequiredMessage("attribute key"))
+	}
+	switch f.Op {
+	case ATTRIBUTE_EQ, "":
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return "", errors.New(error_msgs.GetInvalidMessage("attribute value"))
+		}
+		// Containment rather than -> so the GIN index on attributes is used
+		return fmt.Sprintf("attributes @> jsonb_build_object(%s::text, %s::jsonb)", args.add(f.Key), args.add(string(value))), nil
+	case ATTRIBUTE_EXISTS:
+		return fmt.Sprintf("attributes ? %s", args.add(f.Key)), nil
+	}
+	operator, ok := ATTRIBUTE_NUMERIC_OPERATORS[f.Op]
+	if !ok {
+		return "", errors.New(error_msgs.GetInvalidMessage("attribute op"))
+	}
+	value, ok := numericValue(f.Value)
+	if !ok {
+		return "", errors.New(error_msgs.GetInvalidMessage("attribute value"))
+	}
+	key := args.add(f.Key)
+	// Non numeric values are compared as NULL, which never matches, rather than failing the cast
+	return fmt.Sprintf("CASE WHEN jsonb_typeof(attributes -> %s) = 'number' THEN (attributes ->> %s)::numeric END %s %s", key, key, operator, args.add(value)), nil
+}
+
+func numericValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
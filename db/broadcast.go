@@ -0,0 +1,91 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Batches of notices a subscriber can fall behind by before it misses some
+const LOG_SUBSCRIPTION_BUFFER = 256
+
+// A log that has been written. The log itself is read back through GetStreamLogs so filters and permissions apply.
+type LogNotice struct {
+	Id        string `json:"id"`
+	ProjectId string `json:"project_id,omitempty"`
+}
+
+// Receives notices of every log written after it subscribed.
+// A subscriber too slow to keep up stops receiving notices until it calls CatchUp, and should read what it missed with GetLogsSince.
+type LogSubscription struct {
+	Notices chan []LogNotice
+	missed  atomic.Bool
+}
+
+// Whether notices have been dropped since the last call, clearing the flag
+func (s *LogSubscription) CatchUp() bool {
+	return s.missed.Swap(false)
+}
+
+// Fans notices of written logs out to the subscriptions in this process. Logs written by any instance reach it through the logBus.
+type logBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*LogSubscription]bool
+}
+
+func newLogBroadcaster() *logBroadcaster {
+	return &logBroadcaster{subscribers: map[*LogSubscription]bool{}}
+}
+
+func (db Db) SubscribeLogs() *LogSubscription {
+	subscription := &LogSubscription{Notices: make(chan []LogNotice, LOG_SUBSCRIPTION_BUFFER)}
+	db.broadcaster.mu.Lock()
+	defer db.broadcaster.mu.Unlock()
+	db.broadcaster.subscribers[subscription] = true
+	return subscription
+}
+
+func (db Db) UnsubscribeLogs(subscription *LogSubscription) {
+	db.broadcaster.mu.Lock()
+	defer db.broadcaster.mu.Unlock()
+	delete(db.broadcaster.subscribers, subscription)
+}
+
+// Sends notices to every subscriber without blocking, marking those whose buffers are full as having missed them
+func (db Db) PublishLogs(notices []LogNotice) {
+	if len(notices) == 0 {
+		return
+	}
+	db.broadcaster.mu.Lock()
+	defer db.broadcaster.mu.Unlock()
+	for subscription := range db.broadcaster.subscribers {
+		if subscription.missed.Load() {
+			continue
+		}
+		select {
+		case subscription.Notices <- notices:
+		default:
+			subscription.missed.Store(true)
+		}
+	}
+}
+
+// Marks every subscriber as having missed notices, for when some may have been lost on the way
+func (broadcaster *logBroadcaster) missAll() {
+	broadcaster.mu.Lock()
+	defer broadcaster.mu.Unlock()
+	for subscription := range broadcaster.subscribers {
+		subscription.missed.Store(true)
+	}
+}
+
+// Notices of the entries insertLogs wrote
+func writtenNotices(entries []LogEntry, results []LogResult) []LogNotice {
+	notices := []LogNotice{}
+	for i, entry := range entries {
+		if results[i].Id == nil {
+			continue
+		}
+		notices = append(notices, LogNotice{Id: entry.LogId, ProjectId: entry.ProjectId})
+	}
+	return notices
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel every instance LISTENs on for the logs written by any of them
+const LOG_CHANNEL = "every_log_logs"
+
+// Postgres rejects NOTIFY payloads of 8000 bytes or more
+const MAX_NOTIFY_PAYLOAD = 7999
+
+// Back off of the listener's reconnects after losing its connection
+const LISTENER_MIN_RECONNECT = 10 * time.Second
+const LISTENER_MAX_RECONNECT = time.Minute
+
+// A notification of written logs. Batches whose notices don't fit in a payload are sent as ids alone,
+// split across as many notifications as they need, and receivers read the rest back from the log table.
+type logNotification struct {
+	Logs []LogNotice `json:"l,omitempty"`
+	Ids  []string    `json:"i,omitempty"`
+}
+
+// Carries notices of written logs between instances over postgres LISTEN/NOTIFY, so a tail sees logs ingested by any instance.
+// Each instance hears its own notifications too, and that's how its local subscribers are fed.
+type logBus struct {
+	listener *pq.Listener
+}
+
+// Starts listening on LOG_CHANNEL, publishing what's heard to this instance's subscribers
+func (db Db) listenForLogs(connection string) (*logBus, error) {
+	listener := pq.NewListener(connection, LISTENER_MIN_RECONNECT, LISTENER_MAX_RECONNECT, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			db.Logger.Println("log listener:", err)
+		}
+	})
+	err := listener.Listen(LOG_CHANNEL)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	go func() {
+		for notification := range listener.Notify {
+			// pq sends nil after reconnecting, notifications sent while disconnected are lost so every tail has to catch up
+			if notification == nil {
+				db.broadcaster.missAll()
+				continue
+			}
+			db.receiveLogs(notification.Extra)
+		}
+	}()
+	return &logBus{listener: listener}, nil
+}
+
+func (bus *logBus) close() error {
+	return bus.listener.Close()
+}
+
+// Queues notifications of the written logs in the transaction writing them, so they're only sent if it commits.
+// Returns false if they couldn't be queued, in which case the caller should publish them locally after committing.
+func (db Db) notifyLogs(tx *sql.Tx, notices []LogNotice) bool {
+	if db.bus == nil {
+		return false
+	}
+	if len(notices) == 0 {
+		return true
+	}
+	_, err := tx.Exec("SAVEPOINT log_notify")
+	if err != nil {
+		db.Logger.Println(err)
+		return false
+	}
+	for _, payload := range notificationPayloads(notices) {
+		_, err = tx.Exec("SELECT pg_notify($1, $2)", LOG_CHANNEL, payload)
+		if err != nil {
+			db.Logger.Println(err)
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT log_notify")
+			if err != nil {
+				db.Logger.Println(err)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Encodes the notices in one payload if they fit, otherwise their ids in as few payloads as they fit in
+func notificationPayloads(notices []LogNotice) []string {
+	b, _ := json.Marshal(logNotification{Logs: notices})
+	if len(b) <= MAX_NOTIFY_PAYLOAD {
+		return []string{string(b)}
+	}
+	payloads := []string{}
+	ids := []string{}
+	size := len(`{"i":[]}`)
+	for _, notice := range notices {
+		// The id, its quotes and a comma
+		idSize := len(notice.Id) + 3
+		if len(ids) > 0 && size+idSize > MAX_NOTIFY_PAYLOAD {
+			b, _ = json.Marshal(logNotification{Ids: ids})
+			payloads = append(payloads, string(b))
+			ids = []string{}
+			size = len(`{"i":[]}`)
+		}
+		ids = append(ids, notice.Id)
+		size += idSize
+	}
+	b, _ = json.Marshal(logNotification{Ids: ids})
+	return append(payloads, string(b))
+}
+
+// Publishes a notification heard on LOG_CHANNEL, reading the projects of logs that were sent as ids alone
+func (db Db) receiveLogs(payload string) {
+	var notification logNotification
+	err := json.Unmarshal([]byte(payload), &notification)
+	if err != nil {
+		db.Logger.Println("log listener:", err)
+		return
+	}
+	notices := notification.Logs
+	if len(notification.Ids) > 0 {
+		notices, err = db.getLogNotices(notification.Ids)
+		if err != nil {
+			// Without the projects the tails can still filter the logs themselves
+			notices = []LogNotice{}
+			for _, id := range notification.Ids {
+				notices = append(notices, LogNotice{Id: id})
+			}
+		}
+	}
+	db.PublishLogs(notices)
+}
+
+func (db Db) getLogNotices(ids []string) ([]LogNotice, error) {
+	rows, err := db.Db.Query("SELECT id, project_id FROM log WHERE id = ANY($1::uuid[]) ORDER BY commit_seq, id", pq.Array(ids))
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	notices := []LogNotice{}
+	for rows.Next() {
+		var notice LogNotice
+		err = rows.Scan(&notice.Id, &notice.ProjectId)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, err
+		}
+		notices = append(notices, notice)
+	}
+	return notices, rows.Err()
+}
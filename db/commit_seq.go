@@ -0,0 +1,21 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// A log's commit_seq is the id of the transaction that wrote it, set by the INSERT's column default so writers
+// never wait on each other. Transactions don't commit in id order, so a log with a lower commit_seq can still appear
+// after one with a higher commit_seq was read, but only from a transaction that was running when it was read.
+// Every transaction with an id below the horizon has ended, so nothing new can appear below it.
+func (db Db) streamHorizon() (int64, error) {
+	var horizon int64
+	err := db.Db.QueryRow("SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint").Scan(&horizon)
+	if err != nil {
+		db.Logger.Println(err)
+		return 0, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return horizon, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Position of the last log of a page in GetLogs' (created_at, id) or (rank, created_at, id) order.
+// Clients get it base64 encoded and shouldn't rely on its contents.
+type logCursor struct {
+	Rank      *float32  `json:"r,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	Id        string    `json:"id"`
+}
+
+func encodeLogCursor(log Log, ranked bool) string {
+	cursor := logCursor{CreatedAt: log.CreatedAt, Id: log.Id}
+	if ranked {
+		cursor.Rank = log.Rank
+	}
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeLogCursor(s string) (logCursor, error) {
+	var cursor logCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, errors.New(error_msgs.GetInvalidMessage("cursor"))
+	}
+	err = json.Unmarshal(b, &cursor)
+	if err != nil || cursor.CreatedAt.IsZero() || !isUuid(cursor.Id) {
+		return cursor, errors.New(error_msgs.GetInvalidMessage("cursor"))
+	}
+	return cursor, nil
+}
+
+// Position of a log in (commit_seq, id) order, used as the event id of a live tail so a client can resume after reconnecting.
+// Logs with a commit_seq from Horizon up to CommitSeq may have committed after the log was read, so a tail resuming
+// from it reads them again along with everything after it. Those already sent are sent twice rather than lost.
+type streamCursor struct {
+	CommitSeq int64  `json:"s"`
+	Horizon   int64  `json:"h"`
+	Id        string `json:"id"`
+}
+
+func encodeStreamCursor(cursor streamCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// The event id of a log sent to a live tail
+func StreamCursor(log Log) string {
+	var seq int64
+	if log.CommitSeq != nil {
+		seq = *log.CommitSeq
+	}
+	horizon := seq
+	if log.horizon > 0 && log.horizon < seq {
+		horizon = log.horizon
+	}
+	return encodeStreamCursor(streamCursor{CommitSeq: seq, Horizon: horizon, Id: log.Id})
+}
+
+// A cursor positioned after every log committed so far, for tails that don't resume from an earlier event.
+// Logs from transactions still running are after it, so a few committed just before it may be sent too.
+func (db Db) StreamCursorNow() (string, error) {
+	horizon, err := db.streamHorizon()
+	if err != nil {
+		return "", err
+	}
+	return encodeStreamCursor(streamCursor{CommitSeq: horizon, Horizon: horizon, Id: "00000000-0000-0000-0000-000000000000"}), nil
+}
+
+func decodeStreamCursor(s string) (streamCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return streamCursor{}, errors.New(error_msgs.GetInvalidMessage("Last-Event-ID"))
+	}
+	// The position and horizon are required so cursors from before commit_seq held transaction ids aren't read as the start of the table
+	var cursor struct {
+		CommitSeq *int64 `json:"s"`
+		Horizon   *int64 `json:"h"`
+		Id        string `json:"id"`
+	}
+	err = json.Unmarshal(b, &cursor)
+	if err != nil || cursor.CommitSeq == nil || cursor.Horizon == nil || *cursor.Horizon < 0 || *cursor.Horizon > *cursor.CommitSeq || !isUuid(cursor.Id) {
+		return streamCursor{}, errors.New(error_msgs.GetInvalidMessage("Last-Event-ID"))
+	}
+	return streamCursor{CommitSeq: *cursor.CommitSeq, Horizon: *cursor.Horizon, Id: cursor.Id}, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -30,13 +31,23 @@ func getDbCredentials() dbCredentials {
 }
 
 type Db struct {
-	Db     *sql.DB
-	Logger *log.Logger
+	Db        *sql.DB
+	Logger    *log.Logger
+	processes *processCache
+	// Applied to client timestamps at ingest, from LOG_TIMESTAMP_POLICY, LOG_MAX_FUTURE_SKEW and LOG_MAX_PAST_SKEW
+	timestamps TimestampPolicy
+	// How long idempotency keys are remembered, from IDEMPOTENCY_WINDOW
+	idempotencyWindow time.Duration
+	// Live tails of this process, notified of every log written
+	broadcaster *logBroadcaster
+	// Notifies every instance's broadcaster of the logs written by any of them
+	bus *logBus
 }
 
 func NewDb(logger *log.Logger) Db {
 	credentials := getDbCredentials()
-	db, err := sql.Open("postgres", fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable", credentials.User, credentials.Password, credentials.Name))
+	connection := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable", credentials.User, credentials.Password, credentials.Name)
+	db, err := sql.Open("postgres", connection)
 	if err != nil {
 		panic(err)
 	}
@@ -48,10 +59,29 @@ func NewDb(logger *log.Logger) Db {
 	if err != nil {
 		panic(err)
 	}
-	return Db{db, logger}
+	timestamps, err := getTimestampPolicy()
+	if err != nil {
+		panic(err)
+	}
+	idempotencyWindow, err := getIdempotencyWindow()
+	if err != nil {
+		panic(err)
+	}
+	database := Db{Db: db, Logger: logger, processes: newProcessCache(PROCESS_CACHE_SIZE), timestamps: timestamps, idempotencyWindow: idempotencyWindow, broadcaster: newLogBroadcaster()}
+	database.bus, err = database.listenForLogs(connection)
+	if err != nil {
+		panic(err)
+	}
+	return database
 }
 
 func (db Db) Close() {
+	if db.bus != nil {
+		err := db.bus.close()
+		if err != nil {
+			db.Logger.Println(err)
+		}
+	}
 	err := db.Db.Close()
 	if err != nil {
 		fmt.Println("failed to close db!")
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Most processes and projects counted in a facet, the ones with the most logs are kept
+const MAX_FACET_VALUES = 50
+
+// Attribute facets count the newest logs matching the filter, the most common keys and their most common values
+const ATTRIBUTE_FACET_SAMPLE = 10000
+const MAX_ATTRIBUTE_FACET_KEYS = 20
+const MAX_ATTRIBUTE_FACET_VALUES = 10
+
+type LevelFacet struct {
+	LogLevel
+	Count int `json:"count"`
+}
+
+type ProcessFacet struct {
+	Id        string `json:"id"`
+	ProjectId string `json:"project_id"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+}
+
+type ProjectFacet struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type AttributeValueFacet struct {
+	// The value as json, only strings, numbers and booleans are counted
+	Value json.RawMessage `json:"value"`
+	Count int             `json:"count"`
+}
+
+type AttributeFacet struct {
+	Key string `json:"key"`
+	// Logs in the sample with the key
+	Count  int                   `json:"count"`
+	Values []AttributeValueFacet `json:"values"`
+}
+
+// Counts of the logs matching a filter by level, process, project and attribute, most common first.
+// Each of the level, process and project facets ignores the filter's own field, so it counts the alternatives to what's selected.
+type LogFacets struct {
+	Levels     []LevelFacet     `json:"levels"`
+	Processes  []ProcessFacet   `json:"processes"`
+	Projects   []ProjectFacet   `json:"projects"`
+	Attributes []AttributeFacet `json:"attributes"`
+}
+
+// What the log view's filters can choose from
+type FilterItems struct {
+	Projects []Project `json:"projects"`
+	Orgs     []Org     `json:"orgs"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Facets   LogFacets `json:"facets"`
+}
+
+// Returns the projects and orgs the user can access, and the facets of the logs matching the filter between its From and To,
+// which default to the DEFAULT_STATS_RANGE up to now
+func (db Db) GetFilterItems(userId string, filter LogFilter) (FilterItems, error) {
+	from, to, err := statsRange(&filter)
+	if err != nil {
+		return FilterItems{}, err
+	}
+	items := FilterItems{From: from, To: to}
+	items.Projects, err = db.GetProjects(userId)
+	if err != nil {
+		return FilterItems{}, err
+	}
+	items.Orgs, err = db.GetOrgs(userId, nil, nil, nil, nil)
+	if err != nil {
+		return FilterItems{}, err
+	}
+	items.Facets.Levels, err = db.getLevelFacets(userId, filter)
+	if err != nil {
+		return FilterItems{}, err
+	}
+	items.Facets.Processes, err = db.getProcessFacets(userId, filter)
+	if err != nil {
+		return FilterItems{}, err
+	}
+	items.Facets.Projects, err = db.getProjectFacets(userId, filter)
+	if err != nil {
+		return FilterItems{}, err
+	}
+	items.Facets.Attributes, err = db.getAttributeFacets(userId, filter)
+	if err != nil {
+		return FilterItems{}, err
+	}
+	return items, nil
+}
+
+// Runs a facet query, whose %s is filled with the conditions of the filter, scanning each row with scan
+func (db Db) queryFacet(userId string, filter LogFilter, query string, scan func(row interface{ Scan(dest ...any) error }) error) error {
+	textSearch, args, conditions, err := filter.conditions(userId)
+	if err != nil {
+		return err
+	}
+	rows, done, err := db.querySearch(textSearch, fmt.Sprintf(query, allOf(conditions)), args)
+	if err != nil {
+		return err
+	}
+	defer done()
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			db.Logger.Println(err)
+			return errors.New(error_msgs.DATABASE_ERROR)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return db.searchError(err)
+	}
+	return nil
+}
+
+func (db Db) getLevelFacets(userId string, filter LogFilter) ([]LevelFacet, error) {
+	filter.Levels = nil
+	filter.LevelIds = nil
+	facets := []LevelFacet{}
+	err := db.queryFacet(userId, filter, `SELECT log_level.id, log_level.project_id, log_level.value, log_level.severity, counts.count
+FROM (SELECT level_id, count(*) AS count FROM log WHERE %s GROUP BY level_id) AS counts
+INNER JOIN log_level ON log_level.id = counts.level_id
+ORDER BY log_level.severity, log_level.id`, func(row interface{ Scan(dest ...any) error }) error {
+		var facet LevelFacet
+		err := row.Scan(&facet.Id, &facet.ProjectId, &facet.Name, &facet.Severity, &facet.Count)
+		facets = append(facets, facet)
+		return err
+	})
+	return facets, err
+}
+
+func (db Db) getProcessFacets(userId string, filter LogFilter) ([]ProcessFacet, error) {
+	filter.ProcessIds = nil
+	facets := []ProcessFacet{}
+	err := db.queryFacet(userId, filter, `SELECT process.id, process.project_id, process.name, counts.count
+FROM (SELECT process_id, count(*) AS count FROM log WHERE %s AND process_id IS NOT NULL GROUP BY process_id) AS counts
+INNER JOIN process ON process.id = counts.process_id
+ORDER BY counts.count DESC, process.name
+LIMIT `+fmt.Sprint(MAX_FACET_VALUES), func(row interface{ Scan(dest ...any) error }) error {
+		var facet ProcessFacet
+		err := row.Scan(&facet.Id, &facet.ProjectId, &facet.Name, &facet.Count)
+		facets = append(facets, facet)
+		return err
+	})
+	return facets, err
+}
+
+func (db Db) getProjectFacets(userId string, filter LogFilter) ([]ProjectFacet, error) {
+	filter.ProjectIds = nil
+	facets := []ProjectFacet{}
+	err := db.queryFacet(userId, filter, `SELECT project.id, project.name, counts.count
+FROM (SELECT project_id, count(*) AS count FROM log WHERE %s GROUP BY project_id) AS counts
+INNER JOIN project ON project.id = counts.project_id
+ORDER BY counts.count DESC, project.name
+LIMIT `+fmt.Sprint(MAX_FACET_VALUES), func(row interface{ Scan(dest ...any) error }) error {
+		var facet ProjectFacet
+		err := row.Scan(&facet.Id, &facet.Name, &facet.Count)
+		facets = append(facets, facet)
+		return err
+	})
+	return facets, err
+}
+
+// Counts the most common top level attribute keys and their most common scalar values among the newest ATTRIBUTE_FACET_SAMPLE matching logs
+func (db Db) getAttributeFacets(userId string, filter LogFilter) ([]AttributeFacet, error) {
+	facets := []AttributeFacet{}
+	indexes := map[string]int{}
+	err := db.queryFacet(userId, filter, fmt.Sprintf(`WITH sample AS (
+	SELECT attributes FROM log WHERE %%s AND attributes IS NOT NULL ORDER BY created_at DESC LIMIT %d
+), pairs AS (
+	SELECT entry.key, entry.value FROM sample, jsonb_each(sample.attributes) AS entry
+), keys AS (
+	SELECT key, count(*) AS count FROM pairs GROUP BY key ORDER BY count DESC, key LIMIT %d
+), values AS (
+	SELECT pairs.key, pairs.value, count(*) AS count, row_number() OVER (PARTITION BY pairs.key ORDER BY count(*) DESC, pairs.value) AS rank
+	FROM pairs
+	INNER JOIN keys ON keys.key = pairs.key
+	WHERE jsonb_typeof(pairs.value) IN ('string', 'number', 'boolean')
+	GROUP BY pairs.key, pairs.value
+)
+SELECT keys.key, keys.count, values.value, values.count
+FROM keys
+LEFT JOIN values ON values.key = keys.key AND values.rank <= %d
+ORDER BY keys.count DESC, keys.key, values.count DESC, values.value`, ATTRIBUTE_FACET_SAMPLE, MAX_ATTRIBUTE_FACET_KEYS, MAX_ATTRIBUTE_FACET_VALUES), func(row interface{ Scan(dest ...any) error }) error {
+		var key string
+		var keyCount int
+		var value []byte
+		var valueCount *int
+		err := row.Scan(&key, &keyCount, &value, &valueCount)
+		if err != nil {
+			return err
+		}
+		i, ok := indexes[key]
+		if !ok {
+			i = len(facets)
+			indexes[key] = i
+			facets = append(facets, AttributeFacet{Key: key, Count: keyCount, Values: []AttributeValueFacet{}})
+		}
+		// Keys whose values are all objects, arrays or null have no values to count
+		if valueCount != nil {
+			facets[i].Values = append(facets[i].Values, AttributeValueFacet{Value: value, Count: *valueCount})
+		}
+		return nil
+	})
+	return facets, err
+}
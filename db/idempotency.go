@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/lib/pq"
+)
+
+// How long a key is remembered, overridden with IDEMPOTENCY_WINDOW
+const DEFAULT_IDEMPOTENCY_WINDOW = 24 * time.Hour
+
+// A key reserved this long ago whose log still isn't committed is taken to be abandoned, by an instance that died
+// before writing it, and can be reserved again. Longer than the ingest queue ever holds a log.
+const IDEMPOTENCY_PENDING_TIMEOUT = 5 * time.Minute
+
+// Matches the length limit on other client supplied names
+const MAX_IDEMPOTENCY_KEY_LENGTH = 255
+
+func getIdempotencyWindow() (time.Duration, error) {
+	value := os.Getenv("IDEMPOTENCY_WINDOW")
+	if value == "" {
+		return DEFAULT_IDEMPOTENCY_WINDOW, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("IDEMPOTENCY_WINDOW: %w", err)
+	}
+	return window, nil
+}
+
+// Reserves the idempotency keys of the prepared entries of one project, in log_idempotency so every instance sees them.
+// An entry whose key is held by a committed log within the window is marked as a duplicate of it.
+// One whose key is held by a log that hasn't committed yet, or that repeats a key earlier in the batch, fails with IDEMPOTENCY_IN_PROGRESS,
+// so a retry is only told it's a duplicate once the original is known to be written.
+func (db Db) reserveIdempotencyKeys(projectId string, entries []LogEntry, errs []error, now time.Time) error {
+	keys := []string{}
+	logIds := []string{}
+	indexes := map[string]int{}
+	for i, entry := range entries {
+		if errs[i] != nil || entry.Id == nil {
+			continue
+		}
+		if _, ok := indexes[*entry.Id]; ok {
+			errs[i] = errors.New(error_msgs.IDEMPOTENCY_IN_PROGRESS)
+			continue
+		}
+		indexes[*entry.Id] = i
+		keys = append(keys, *entry.Id)
+		logIds = append(logIds, entry.LogId)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	expired := now.Add(-db.idempotencyWindow)
+	_, err := db.Db.Exec("DELETE FROM log_idempotency WHERE project_id = $1 AND reserved_at < $2", projectId, expired)
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	rows, err := db.Db.Query(`INSERT INTO log_idempotency (project_id, idempotency_key, log_id, reserved_at)
+SELECT $1, reserved.key, reserved.log_id, $4 FROM unnest($2::text[], $3::uuid[]) AS reserved (key, log_id)
+ON CONFLICT (project_id, idempotency_key) DO UPDATE
+SET log_id = EXCLUDED.log_id, committed = FALSE, reserved_at = EXCLUDED.reserved_at
+WHERE log_idempotency.reserved_at < $5
+OR (NOT log_idempotency.committed AND log_idempotency.reserved_at < $6)
+RETURNING idempotency_key`, projectId, pq.Array(keys), pq.Array(logIds), now, expired, now.Add(-IDEMPOTENCY_PENDING_TIMEOUT))
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	held := map[string]bool{}
+	for _, key := range keys {
+		held[key] = true
+	}
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			db.Logger.Println(err)
+			return errors.New(error_msgs.DATABASE_ERROR)
+		}
+		delete(held, key)
+	}
+	err = rows.Err()
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	if len(held) == 0 {
+		return nil
+	}
+	heldKeys := make([]string, 0, len(held))
+	for key := range held {
+		heldKeys = append(heldKeys, key)
+	}
+	rows, err = db.Db.Query(`SELECT idempotency_key, log_id
+FROM log_idempotency
+WHERE project_id = $1
+AND idempotency_key = ANY($2::text[])
+AND committed`, projectId, pq.Array(heldKeys))
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, logId string
+		err = rows.Scan(&key, &logId)
+		if err != nil {
+			db.Logger.Println(err)
+			return errors.New(error_msgs.DATABASE_ERROR)
+		}
+		i := indexes[key]
+		entries[i].LogId = logId
+		entries[i].duplicate = true
+		delete(held, key)
+	}
+	err = rows.Err()
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	// Still being written, or released since the insert, either way the client should retry
+	for key := range held {
+		errs[indexes[key]] = errors.New(error_msgs.IDEMPOTENCY_IN_PROGRESS)
+	}
+	return nil
+}
+
+// Marks the reservations of the entries written by tx as committed, so once it commits their keys are reported as duplicates
+func (db Db) commitIdempotencyKeys(tx *sql.Tx, entries []LogEntry, results []LogResult) error {
+	projectIds, keys, logIds := idempotencyKeys(entries, func(i int) bool { return results[i].Error == nil })
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`UPDATE log_idempotency
+SET committed = TRUE
+FROM unnest($1::uuid[], $2::text[], $3::uuid[]) AS written (project_id, key, log_id)
+WHERE log_idempotency.project_id = written.project_id
+AND log_idempotency.idempotency_key = written.key
+AND log_idempotency.log_id = written.log_id`, pq.Array(projectIds), pq.Array(keys), pq.Array(logIds))
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return nil
+}
+
+// Forgets the reservations of entries that were prepared but couldn't be written, so retries aren't turned away.
+// Best effort, a reservation that can't be deleted is taken over once it's been pending for IDEMPOTENCY_PENDING_TIMEOUT.
+func (db Db) releaseIdempotencyKeys(entries []LogEntry) {
+	projectIds, keys, logIds := idempotencyKeys(entries, func(int) bool { return true })
+	if len(keys) == 0 {
+		return
+	}
+	_, err := db.Db.Exec(`DELETE FROM log_idempotency
+USING unnest($1::uuid[], $2::text[], $3::uuid[]) AS released (project_id, key, log_id)
+WHERE log_idempotency.project_id = released.project_id
+AND log_idempotency.idempotency_key = released.key
+AND log_idempotency.log_id = released.log_id
+AND NOT log_idempotency.committed`, pq.Array(projectIds), pq.Array(keys), pq.Array(logIds))
+	if err != nil {
+		db.Logger.Println(err)
+	}
+}
+
+// The reservations held by the entries for which include returns true, duplicates hold none
+func idempotencyKeys(entries []LogEntry, include func(i int) bool) ([]string, []string, []string) {
+	projectIds := []string{}
+	keys := []string{}
+	logIds := []string{}
+	for i, entry := range entries {
+		if entry.Id == nil || entry.duplicate || !include(i) {
+			continue
+		}
+		projectIds = append(projectIds, entry.ProjectId)
+		keys = append(keys, *entry.Id)
+		logIds = append(logIds, entry.LogId)
+	}
+	return projectIds, keys, logIds
+}
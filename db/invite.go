@@ -82,9 +82,8 @@ func (db Db) CreateProjectInvite(fromUserId string, toUserId string, projectId s
 		db.Logger.Println(err)
 		return "", errors.New(error_msgs.DATABASE_ERROR)
 	}
-	_, err = db.getPermittedProjectIdFromApiKey(fromUserId, apiKey)
+	_, err = db.GetApiKeyProjectId(fromUserId, apiKey)
 	if err != nil {
-		// TODO: Add unauthorized vs other error handling
 		innerErr := tx.Rollback()
 		if innerErr != nil {
 			db.Logger.Println(innerErr)
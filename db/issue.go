@@ -0,0 +1,278 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/lib/pq"
+)
+
+// ERROR's severity. Logs at or above it, CRITICAL and any more severe project levels included, are grouped into issues.
+const ISSUE_MIN_SEVERITY = 400
+
+// States of an issue. A new occurrence of a resolved issue reopens it as a regression, ignored issues stay ignored.
+const (
+	ISSUE_UNRESOLVED = "unresolved"
+	ISSUE_RESOLVED   = "resolved"
+	ISSUE_IGNORED    = "ignored"
+)
+
+// A distinct failure: every ERROR or more severe log in a project with the same fingerprint
+type Issue struct {
+	Id          string  `json:"id"`
+	ProjectId   string  `json:"project_id"`
+	Fingerprint string  `json:"fingerprint"`
+	Title       string  `json:"title"`
+	Exception   *string `json:"exception"`
+	// Innermost in app frame of the first occurrence's traceback
+	Culprit     *string    `json:"culprit"`
+	LevelId     int        `json:"level_id"`
+	Status      string     `json:"status"`
+	Count       int64      `json:"count"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	ResolvedAt  *time.Time `json:"resolved_at"`
+	// When an occurrence after the issue was resolved last reopened it
+	RegressedAt *time.Time     `json:"regressed_at"`
+	Processes   []IssueProcess `json:"processes"`
+}
+
+// A process an issue occurred in
+type IssueProcess struct {
+	Id         string    `json:"id"`
+	Name       string    `json:"name"`
+	Count      int64     `json:"count"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// Columns read for an Issue, in the order scanned by scanIssue. The affected processes are aggregated as json, most affected first.
+const ISSUE_SELECT_COLUMNS = `issue.id, issue.project_id, issue.fingerprint, issue.title, issue.exception, issue.culprit, issue.level_id, issue.status, issue.count,
+issue.first_seen_at, issue.last_seen_at, issue.resolved_at, issue.regressed_at,
+COALESCE((SELECT json_agg(json_build_object('id', process.id, 'name', process.name, 'count', issue_process.count, 'last_seen_at', issue_process.last_seen_at) ORDER BY issue_process.count DESC)
+FROM issue_process
+JOIN process ON process.id = issue_process.process_id
+WHERE issue_process.issue_id = issue.id), '[]')`
+
+func scanIssue(row interface{ Scan(dest ...any) error }) (Issue, error) {
+	var issue Issue
+	var processes []byte
+	err := row.Scan(&issue.Id, &issue.ProjectId, &issue.Fingerprint, &issue.Title, &issue.Exception, &issue.Culprit, &issue.LevelId, &issue.Status, &issue.Count,
+		&issue.FirstSeenAt, &issue.LastSeenAt, &issue.ResolvedAt, &issue.RegressedAt, &processes)
+	if err != nil {
+		return issue, err
+	}
+	err = json.Unmarshal(processes, &issue.Processes)
+	return issue, err
+}
+
+// Lists a project's issues for a user permitted to see it, most recently seen first. An empty status lists every issue.
+func (db Db) GetIssues(userId string, projectId string, status string) ([]Issue, error) {
+	if status != "" && !isIssueStatus(status) {
+		return nil, errors.New(error_msgs.GetInvalidMessage("status"))
+	}
+	err := db.canReadProject(userId, projectId)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Db.Query(`SELECT `+ISSUE_SELECT_COLUMNS+`
+FROM issue
+WHERE issue.project_id = $1
+AND ($2 = '' OR issue.status = $2)
+ORDER BY issue.last_seen_at DESC, issue.id`, projectId, status)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	issues := []Issue{}
+	for rows.Next() {
+		issue, err := scanIssue(rows)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// Returns a single issue if it's in a project the user is permitted on
+func (db Db) GetIssue(userId string, issueId string) (Issue, error) {
+	if !isUuid(issueId) {
+		return Issue{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	row := db.Db.QueryRow(`SELECT `+ISSUE_SELECT_COLUMNS+`
+FROM issue
+WHERE issue.id = $1
+AND issue.project_id IN (`+readableProjects("$2")+`)`, issueId, userId)
+	issue, err := scanIssue(row)
+	if err == sql.ErrNoRows {
+		return Issue{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	if err != nil {
+		db.Logger.Println(err)
+		return Issue{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return issue, nil
+}
+
+// Resolves, ignores or reopens an issue, returning it as updated. Anyone who can read the issue can triage it.
+func (db Db) SetIssueStatus(userId string, issueId string, status string) (Issue, error) {
+	if !isIssueStatus(status) {
+		return Issue{}, errors.New(error_msgs.GetInvalidMessage("status"))
+	}
+	if !isUuid(issueId) {
+		return Issue{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	result, err := db.Db.Exec(`UPDATE issue
+SET status = $1,
+resolved_at = CASE WHEN $1 = 'resolved' THEN CURRENT_TIMESTAMP END
+WHERE id = $2
+AND project_id IN (`+readableProjects("$3")+`)`, status, issueId, userId)
+	if err != nil {
+		db.Logger.Println(err)
+		return Issue{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		db.Logger.Println(err)
+		return Issue{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	if updated == 0 {
+		return Issue{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	return db.GetIssue(userId, issueId)
+}
+
+func isIssueStatus(status string) bool {
+	return status == ISSUE_UNRESOLVED || status == ISSUE_RESOLVED || status == ISSUE_IGNORED
+}
+
+type issueKey struct {
+	projectId   string
+	fingerprint string
+}
+
+// The occurrences of one issue in a written batch
+type issueOccurrences struct {
+	entry       LogEntry
+	logIds      []string
+	firstSeenAt time.Time
+	lastSeenAt  time.Time
+	// When the server received the latest occurrence, which decides whether a resolved issue regressed.
+	// Client timestamps can't, a late or skewed one could predate the resolution and never reopen it.
+	lastReceivedAt time.Time
+	processes      map[string]*IssueProcess
+}
+
+// Groups the written entries that have a fingerprint into their issues, counting the occurrences and linking the logs.
+// Best effort like touchProcesses, if it fails the logs are still written, just without an issue.
+func (db Db) recordIssues(tx *sql.Tx, entries []LogEntry, results []LogResult) error {
+	groups := map[issueKey]*issueOccurrences{}
+	keys := []issueKey{}
+	for i, entry := range entries {
+		if entry.fingerprint == nil || results[i].Id == nil {
+			continue
+		}
+		key := issueKey{projectId: entry.ProjectId, fingerprint: entry.fingerprint.Hash}
+		group, ok := groups[key]
+		if !ok {
+			group = &issueOccurrences{entry: entry, firstSeenAt: entry.createdAt, lastSeenAt: entry.createdAt, processes: map[string]*IssueProcess{}}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.logIds = append(group.logIds, entry.LogId)
+		if entry.receivedAt.After(group.lastReceivedAt) {
+			group.lastReceivedAt = entry.receivedAt
+		}
+		if entry.createdAt.Before(group.firstSeenAt) {
+			group.firstSeenAt = entry.createdAt
+		}
+		if !entry.createdAt.Before(group.lastSeenAt) {
+			group.lastSeenAt = entry.createdAt
+			group.entry = entry
+		}
+		if entry.ProcessId != nil {
+			process, ok := group.processes[*entry.ProcessId]
+			if !ok {
+				process = &IssueProcess{Id: *entry.ProcessId, LastSeenAt: entry.createdAt}
+				group.processes[*entry.ProcessId] = process
+			}
+			process.Count++
+			if entry.createdAt.After(process.LastSeenAt) {
+				process.LastSeenAt = entry.createdAt
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	// Upserting in a fixed order keeps concurrent batches from deadlocking on each other's issues
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].projectId != keys[j].projectId {
+			return keys[i].projectId < keys[j].projectId
+		}
+		return keys[i].fingerprint < keys[j].fingerprint
+	})
+	_, err := tx.Exec("SAVEPOINT issues")
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	for _, key := range keys {
+		err = db.recordIssue(tx, groups[key])
+		if err != nil {
+			db.Logger.Println(err)
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT issues")
+			if err != nil {
+				db.Logger.Println(err)
+				return errors.New(error_msgs.DATABASE_ERROR)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Upserts an issue with a batch's occurrences. An occurrence after the issue was resolved reopens it as a regression.
+func (db Db) recordIssue(tx *sql.Tx, group *issueOccurrences) error {
+	fingerprint := group.entry.fingerprint
+	var exception, culprit *string
+	if fingerprint.Exception != "" {
+		exception = &fingerprint.Exception
+	}
+	if fingerprint.Culprit != "" {
+		culprit = &fingerprint.Culprit
+	}
+	var issueId string
+	err := tx.QueryRow(`INSERT INTO issue (project_id, fingerprint, title, exception, culprit, level_id, count, first_seen_at, last_seen_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (project_id, fingerprint) DO UPDATE SET
+count = issue.count + EXCLUDED.count,
+level_id = EXCLUDED.level_id,
+first_seen_at = LEAST(issue.first_seen_at, EXCLUDED.first_seen_at),
+last_seen_at = GREATEST(issue.last_seen_at, EXCLUDED.last_seen_at),
+status = CASE WHEN issue.status = 'resolved' AND $10 > issue.resolved_at THEN 'unresolved' ELSE issue.status END,
+resolved_at = CASE WHEN issue.status = 'resolved' AND $10 > issue.resolved_at THEN NULL ELSE issue.resolved_at END,
+regressed_at = CASE WHEN issue.status = 'resolved' AND $10 > issue.resolved_at THEN CURRENT_TIMESTAMP ELSE issue.regressed_at END
+RETURNING id`, group.entry.ProjectId, fingerprint.Hash, fingerprint.Title, exception, culprit, group.entry.LevelId, len(group.logIds), group.firstSeenAt, group.lastSeenAt, group.lastReceivedAt).Scan(&issueId)
+	if err != nil {
+		return err
+	}
+	for _, process := range group.processes {
+		_, err = tx.Exec(`INSERT INTO issue_process (issue_id, process_id, count, last_seen_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (issue_id, process_id) DO UPDATE SET
+count = issue_process.count + EXCLUDED.count,
+last_seen_at = GREATEST(issue_process.last_seen_at, EXCLUDED.last_seen_at)`, issueId, process.Id, process.Count, process.LastSeenAt)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE log SET issue_id = $1 WHERE id = ANY($2::uuid[])", issueId, pq.Array(group.logIds))
+	return err
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Built in log levels, seeded by sql/create_log_levels.sql
+const (
+	LEVEL_INFO     = 100
+	LEVEL_DEBUG    = 200
+	LEVEL_WARNING  = 300
+	LEVEL_ERROR    = 400
+	LEVEL_CRITICAL = 500
+)
+
+// Names of the built in levels, which projects can't redefine
+var BUILT_IN_LEVEL_NAMES = map[string]int{
+	"INFO":     LEVEL_INFO,
+	"DEBUG":    LEVEL_DEBUG,
+	"WARNING":  LEVEL_WARNING,
+	"ERROR":    LEVEL_ERROR,
+	"CRITICAL": LEVEL_CRITICAL,
+}
+
+// Matches the log_level.value column
+const MAX_LEVEL_NAME_LENGTH = 255
+
+// A built in level, shared by every project, or one a project defined for itself.
+// Severity orders levels independently of their ids, higher is more severe.
+type LogLevel struct {
+	Id        int     `json:"id"`
+	ProjectId *string `json:"project_id"`
+	Name      string  `json:"name"`
+	Severity  int     `json:"severity"`
+}
+
+// Maps the level names used by common logging libraries and protocols onto the built in levels
+func LevelIdFromName(name string) (int, bool) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "INFO", "INFORMATION", "NOTICE":
+		return LEVEL_INFO, true
+	case "DEBUG", "TRACE", "VERBOSE":
+		return LEVEL_DEBUG, true
+	case "WARN", "WARNING":
+		return LEVEL_WARNING, true
+	case "ERROR", "ERR":
+		return LEVEL_ERROR, true
+	case "CRITICAL", "CRIT", "FATAL", "PANIC", "ALERT", "EMERGENCY", "EMERG":
+		return LEVEL_CRITICAL, true
+	}
+	return 0, false
+}
+
+// Upper cased names a level filter matches: the name itself and, for an alias such as WARN, the built in level it stands for
+func levelNames(name string) []string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	names := []string{name}
+	if id, ok := LevelIdFromName(name); ok {
+		for builtIn, builtInId := range BUILT_IN_LEVEL_NAMES {
+			if builtInId == id && builtIn != name {
+				names = append(names, builtIn)
+			}
+		}
+	}
+	return names
+}
+
+// The levels usable in one project, the built in levels plus its own
+type projectLevels struct {
+	ids        map[int]bool
+	names      map[string]int
+	severities map[int]int
+}
+
+// Resolves a level name case insensitively. The project's own levels are checked before the built in aliases,
+// so a project that defines FATAL gets its own level rather than CRITICAL.
+func (l projectLevels) resolve(name string) (int, bool) {
+	if id, ok := l.names[strings.ToUpper(strings.TrimSpace(name))]; ok {
+		return id, true
+	}
+	return LevelIdFromName(name)
+}
+
+func (db Db) getProjectLevels(projectId string) (projectLevels, error) {
+	levels := projectLevels{ids: map[int]bool{}, names: map[string]int{}, severities: map[int]int{}}
+	rows, err := db.Db.Query("SELECT id, value, severity FROM log_level WHERE project_id IS NULL OR project_id = $1", projectId)
+	if err != nil {
+		db.Logger.Println(err)
+		return levels, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var name string
+		var severity int
+		err = rows.Scan(&id, &name, &severity)
+		if err != nil {
+			db.Logger.Println(err)
+			return levels, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		levels.ids[id] = true
+		levels.names[strings.ToUpper(name)] = id
+		levels.severities[id] = severity
+	}
+	return levels, nil
+}
+
+// Lists the built in levels and the project's own, least severe first
+func (db Db) GetLevels(userId string, projectId string) ([]LogLevel, error) {
+	err := db.canReadProject(userId, projectId)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Db.Query(`SELECT id, project_id, value, severity
+FROM log_level
+WHERE project_id IS NULL OR project_id = $1
+ORDER BY severity, id`, projectId)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	levels := []LogLevel{}
+	for rows.Next() {
+		var level LogLevel
+		err = rows.Scan(&level.Id, &level.ProjectId, &level.Name, &level.Severity)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// Defines a level for the project, returning its id. Names are unique per project regardless of case.
+func (db Db) CreateLevel(userId string, projectId string, name string, severity int) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, errors.New(error_msgs.GetRequiredMessage("name"))
+	}
+	if len(name) > MAX_LEVEL_NAME_LENGTH {
+		return 0, errors.New(error_msgs.GetInvalidMessage("name"))
+	}
+	if _, ok := BUILT_IN_LEVEL_NAMES[strings.ToUpper(name)]; ok {
+		return 0, errors.New(error_msgs.GetExistsMessage("level"))
+	}
+	_, err := db.getPermittedProjectId(userId, projectId, nil)
+	if err != nil {
+		return 0, errors.New(error_msgs.UNAUTHORIZED)
+	}
+	var id int
+	err = db.Db.QueryRow("INSERT INTO log_level (project_id, value, severity) VALUES ($1, $2, $3) RETURNING id", projectId, name, severity).Scan(&id)
+	if err != nil {
+		db.Logger.Println(err)
+		if strings.Contains(err.Error(), "duplicate") {
+			return 0, errors.New(error_msgs.GetExistsMessage("level"))
+		}
+		return 0, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return id, nil
+}
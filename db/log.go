@@ -2,126 +2,672 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/issues"
+	"github.com/jesses-code-adventures/every_log/logquery"
+	"github.com/jesses-code-adventures/every_log/traceback"
+	"github.com/lib/pq"
 )
 
 type Log struct {
-	Id        string    `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UserId    string    `json:"user_id"`
-	ProjectId string    `json:"project_id"`
-	LevelId   int       `json:"level_id"`
-	ProcessId *string   `json:"process_id"`
-	Message   *string   `json:"message"`
-	Traceback *string   `json:"traceback"`
-}
-
-func (db Db) CreateLog(userId string, project_id string, level_id int, process_id *string, message string, traceback *string, apiKey string) (string, error) {
-	var logId string
+	Id string `json:"id"`
+	// Event time, as sent by the client or the time the log was received
+	CreatedAt  time.Time `json:"created_at"`
+	ReceivedAt time.Time `json:"received_at"`
+	// Set when the client's timestamp was outside the accepted clock skew
+	Skewed       bool            `json:"skewed"`
+	UserId       string          `json:"user_id"`
+	ProjectId    string          `json:"project_id"`
+	LevelId      int             `json:"level_id"`
+	ProcessId    *string         `json:"process_id"`
+	Message      *string         `json:"message"`
+	Traceback    *string         `json:"traceback"`
+	Attributes   json.RawMessage `json:"attributes"`
+	TraceId      *string         `json:"trace_id"`
+	SpanId       *string         `json:"span_id"`
+	ParentSpanId *string         `json:"parent_span_id"`
+	// The issue an ERROR or more severe log was grouped into
+	IssueId *string `json:"issue_id"`
+	// Id of the transaction that wrote the log, see streamHorizon. Only used for stream cursors.
+	CommitSeq *int64 `json:"-"`
+	// The streamHorizon when a stream read the log
+	horizon int64
+	// Stacks parsed from the traceback, only read by GetLog
+	TracebackFrames json.RawMessage `json:"traceback_frames,omitempty"`
+	// Set by GetLogs when searching
+	Rank      *float32      `json:"rank,omitempty"`
+	Highlight *LogHighlight `json:"highlight,omitempty"`
+}
+
+// Columns read for a Log, in the order scanned by scanLog
+const LOG_SELECT_COLUMNS = "id, created_at, received_at, skewed, user_id, project_id, level_id, process_id, message, traceback, attributes, trace_id, span_id, parent_span_id, issue_id, commit_seq"
+
+// Scans LOG_SELECT_COLUMNS into a Log, followed by any extra columns the query selected
+func scanLog(row interface{ Scan(dest ...any) error }, extra ...any) (Log, error) {
+	var log Log
+	dest := []any{&log.Id, &log.CreatedAt, &log.ReceivedAt, &log.Skewed, &log.UserId, &log.ProjectId, &log.LevelId, &log.ProcessId, &log.Message, &log.Traceback, &log.Attributes, &log.TraceId, &log.SpanId, &log.ParentSpanId, &log.IssueId, &log.CommitSeq}
+	err := row.Scan(append(dest, extra...)...)
+	return log, err
+}
+
+// A log as submitted for ingestion.
+// UserId and LogId are filled in by PrepareLogs so entries can be written later, possibly alongside other users' logs.
+type LogEntry struct {
+	LogId  string `json:"-"`
+	UserId string `json:"-"`
+	// Idempotency key chosen by the client. A repeat within the window gets the original log's id and isn't written again,
+	// or IDEMPOTENCY_IN_PROGRESS while the original is still being written.
+	Id        *string `json:"id"`
+	ProjectId string  `json:"project_id"`
+	LevelId   int     `json:"level_id"`
+	// Name of a built in or project level, resolved to LevelId. LevelId is kept as the fallback when the name isn't known.
+	Level     *string `json:"level"`
+	ProcessId *string `json:"process_id"`
+	// Name of the process, registered in the project the first time it's seen. Can't be combined with ProcessId.
+	Process   *string `json:"process"`
+	Message   string  `json:"message"`
+	Traceback *string `json:"traceback"`
+	// Structured context that doesn't belong in the message, such as request_id or duration_ms
+	Attributes map[string]any `json:"attributes"`
+	// When the event happened according to the client, defaults to when it was received
+	Timestamp *time.Time `json:"timestamp"`
+	// W3C trace context, hex encoded. A traceparent header can fill in the trace and span, see ApplyTraceparent.
+	TraceId      *string `json:"trace_id"`
+	SpanId       *string `json:"span_id"`
+	ParentSpanId *string `json:"parent_span_id"`
+	// Attributes encoded by validateLogEntry, nil when there are none
+	attributesJson []byte
+	// Stacks parsed from Traceback by validateLogEntry, nil when it isn't a recognised trace
+	stacks     []traceback.Stack
+	framesJson []byte
+	// Set by validateLogEntry for logs severe enough to be grouped into an issue
+	fingerprint *issues.Fingerprint
+	// Set by validateLogEntry from Timestamp and the db's TimestampPolicy
+	createdAt  time.Time
+	receivedAt time.Time
+	skewed     bool
+	// Set by PrepareLogs when Id was already written, LogId is then the original log's
+	duplicate bool
+}
+
+// Whether PrepareLogs matched the entry's idempotency key to an earlier log, in which case it mustn't be written
+func (entry LogEntry) IsDuplicate() bool {
+	return entry.duplicate
+}
+
+// The outcome of ingesting a single entry of a batch
+type LogResult struct {
+	Index int     `json:"index"`
+	Id    *string `json:"id,omitempty"`
+	Error *string `json:"error,omitempty"`
+	// The entry's idempotency key had been written, Id is the original log's
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// Rows per insert statement, keeps the parameter count well under postgres' limit
+const INSERT_CHUNK_SIZE = 500
+
+// Columns written for each entry, in the order returned by insertArgs
+const LOG_INSERT_COLUMNS = "id, user_id, project_id, level_id, process_id, message, traceback, attributes, created_at, received_at, skewed, trace_id, span_id, parent_span_id, traceback_frames"
+
+func (entry LogEntry) insertArgs() []any {
+	var attributes any
+	if entry.attributesJson != nil {
+		attributes = string(entry.attributesJson)
+	}
+	var frames any
+	if entry.framesJson != nil {
+		frames = string(entry.framesJson)
+	}
+	return []any{entry.LogId, entry.UserId, entry.ProjectId, entry.LevelId, entry.ProcessId, entry.Message, entry.Traceback, attributes, entry.createdAt, entry.receivedAt, entry.skewed, entry.TraceId, entry.SpanId, entry.ParentSpanId, frames}
+}
+
+// Narrows GetLogs. Empty and nil fields aren't filtered on, a log matches a list field if it matches any of its values.
+type LogFilter struct {
+	ProjectIds []string
+	LevelIds   []int
+	// Level names, matched against the built in levels and each log's project levels
+	Levels     []string
+	ProcessIds []string
+	IssueIds   []string
+	OrgIds     []string
+	TraceIds   []string
+	From       *time.Time
+	To         *time.Time
+	Attributes []AttributeFilter
+	// A query in the log query language, see logquery.Parse, ANDed with the other fields
+	Query string
+	// Full text search over message and traceback, see search.ToTsquery for the syntax. Match changes how it's matched.
+	Search string
+	// One of the MATCH_ modes, MATCH_FULLTEXT when empty
+	Match string
+	// Threshold of a MATCH_FUZZY search, DEFAULT_SIMILARITY_THRESHOLD when 0
+	Similarity float64
+	// LOG_SORT_TIME, the default, or LOG_SORT_RANK to order a search by relevance
+	Sort string
+	// Most logs in a page, DEFAULT_LOG_LIMIT when 0
+	Limit int
+	// The NextCursor of the previous page, empty for the first
+	Cursor string
+}
+
+const DEFAULT_LOG_LIMIT = 100
+const MAX_LOG_LIMIT = 1000
+
+// One page of GetLogs. NextCursor is nil on the last page.
+type LogPage struct {
+	Logs       []Log   `json:"logs"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// Compiles every field of the filter but its paging to conditions on the log table, limited to the projects the user can read.
+// The search is returned so its rank and headlines can be selected.
+func (filter LogFilter) conditions(userId string) (logSearch, queryArgs, []string, error) {
+	for field, ids := range map[string][]string{"project_id": filter.ProjectIds, "process_id": filter.ProcessIds, "issue_id": filter.IssueIds, "org_id": filter.OrgIds} {
+		for _, id := range ids {
+			if !isUuid(id) {
+				return logSearch{}, queryArgs{}, nil, errors.New(error_msgs.GetInvalidMessage(field))
+			}
+		}
+	}
+	var textSearch logSearch
+	if filter.Search != "" {
+		var err error
+		textSearch, err = newLogSearch(filter.Search, filter.Match, filter.Similarity)
+		if err != nil {
+			return logSearch{}, queryArgs{}, nil, err
+		}
+	}
+	args := queryArgs{values: []any{userId}}
+	// The search's arguments always follow the user so its sql can refer to them
+	args.values = append(args.values, textSearch.args...)
+	conditions := []string{"project_id IN (" + readableProjects("$1") + ")"}
+	if textSearch.condition != "" {
+		conditions = append(conditions, textSearch.condition)
+	}
+	if filter.Query != "" {
+		node, err := logquery.Parse(filter.Query, time.Now())
+		if err != nil {
+			return logSearch{}, queryArgs{}, nil, errors.New(error_msgs.GetSearchErrorMessage(err.Error()))
+		}
+		condition, err := compileLogQuery(node, &args)
+		if err != nil {
+			return logSearch{}, queryArgs{}, nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(filter.ProjectIds) > 0 {
+		conditions = append(conditions, "project_id = ANY("+args.add(pq.Array(filter.ProjectIds))+"::uuid[])")
+	}
+	if len(filter.LevelIds) > 0 {
+		conditions = append(conditions, "level_id = ANY("+args.add(pq.Array(filter.LevelIds))+"::int[])")
+	}
+	if len(filter.Levels) > 0 {
+		names := []string{}
+		for _, level := range filter.Levels {
+			names = append(names, levelNames(level)...)
+		}
+		conditions = append(conditions, levelNameCondition(args.add(pq.Array(names))))
+	}
+	if len(filter.ProcessIds) > 0 {
+		conditions = append(conditions, "process_id = ANY("+args.add(pq.Array(filter.ProcessIds))+"::uuid[])")
+	}
+	if len(filter.IssueIds) > 0 {
+		conditions = append(conditions, "issue_id = ANY("+args.add(pq.Array(filter.IssueIds))+"::uuid[])")
+	}
+	if len(filter.OrgIds) > 0 {
+		conditions = append(conditions, "project_id IN (SELECT project_id FROM project_org WHERE org_id = ANY("+args.add(pq.Array(filter.OrgIds))+"::uuid[]))")
+	}
+	if len(filter.TraceIds) > 0 {
+		traceIds := make([]string, len(filter.TraceIds))
+		for i, traceId := range filter.TraceIds {
+			traceIds[i] = strings.ToLower(traceId)
+		}
+		conditions = append(conditions, "trace_id = ANY("+args.add(pq.Array(traceIds))+")")
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= "+args.add(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at <= "+args.add(*filter.To))
+	}
+	for _, attribute := range filter.Attributes {
+		condition, err := attribute.condition(&args)
+		if err != nil {
+			return logSearch{}, queryArgs{}, nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return textSearch, args, conditions, nil
+}
+
+// Returns a page of the logs in every project the user can read, newest event first. Logs are ordered by (created_at, id)
+// so pages stay stable while new logs arrive. Searches can instead be ordered by (rank, created_at, id),
+// and return each log's rank and highlighted snippets.
+func (db Db) GetLogs(userId string, filter LogFilter) (LogPage, error) {
+	limit := filter.Limit
+	if limit == 0 {
+		limit = DEFAULT_LOG_LIMIT
+	}
+	if limit < 0 || limit > MAX_LOG_LIMIT {
+		return LogPage{}, errors.New(error_msgs.GetInvalidMessage("limit"))
+	}
+	textSearch, args, conditions, err := filter.conditions(userId)
+	if err != nil {
+		return LogPage{}, err
+	}
+	sort := filter.Sort
+	if sort == "" {
+		sort = LOG_SORT_TIME
+	}
+	if sort != LOG_SORT_TIME && (sort != LOG_SORT_RANK || textSearch.rank == "") {
+		return LogPage{}, errors.New(error_msgs.GetInvalidMessage("sort"))
+	}
+	columns := LOG_SELECT_COLUMNS
+	order := "created_at DESC, id DESC"
+	if textSearch.rank != "" {
+		columns += ", " + textSearch.rank + " AS rank"
+		if sort == LOG_SORT_RANK {
+			order = "rank DESC, " + order
+		}
+	}
+	if filter.Cursor != "" {
+		cursor, err := decodeLogCursor(filter.Cursor)
+		if err != nil {
+			return LogPage{}, err
+		}
+		if sort == LOG_SORT_RANK {
+			if cursor.Rank == nil {
+				return LogPage{}, errors.New(error_msgs.GetInvalidMessage("cursor"))
+			}
+			conditions = append(conditions, fmt.Sprintf("(%s, created_at, id) < (%s::real, %s, %s)", textSearch.rank, args.add(*cursor.Rank), args.add(cursor.CreatedAt), args.add(cursor.Id)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(created_at, id) < (%s, %s)", args.add(cursor.CreatedAt), args.add(cursor.Id)))
+		}
+	}
+	// One more than the limit is read to tell whether there's another page
+	query := fmt.Sprintf("SELECT %s FROM log WHERE %s ORDER BY %s LIMIT %s", columns, allOf(conditions), order, args.add(limit+1))
+	// Ranks and highlights are added around the page so they're only computed for the logs returned
+	columns = LOG_SELECT_COLUMNS
+	if textSearch.rank != "" {
+		columns += ", rank"
+	}
+	if textSearch.headlines != "" {
+		columns += ", " + textSearch.headlines
+	}
+	if columns != LOG_SELECT_COLUMNS {
+		query = "SELECT " + columns + " FROM (" + query + ") AS page ORDER BY " + order
+	}
+	rows, done, err := db.querySearch(textSearch, query, args)
+	if err != nil {
+		return LogPage{}, err
+	}
+	defer done()
+	page := LogPage{Logs: []Log{}}
+	for rows.Next() {
+		var rank float32
+		var highlight LogHighlight
+		extra := []any{}
+		if textSearch.rank != "" {
+			extra = append(extra, &rank)
+		}
+		if textSearch.headlines != "" {
+			extra = append(extra, &highlight.Message, &highlight.Traceback)
+		}
+		log, err := scanLog(rows, extra...)
+		if err != nil {
+			db.Logger.Println(err)
+			return LogPage{}, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		if textSearch.rank != "" {
+			log.Rank = &rank
+		}
+		if textSearch.headlines != "" {
+			log.Highlight = &highlight
+		}
+		page.Logs = append(page.Logs, log)
+	}
+	err = rows.Err()
+	if err != nil {
+		return LogPage{}, db.searchError(err)
+	}
+	if len(page.Logs) > limit {
+		page.Logs = page.Logs[:limit]
+		cursor := encodeLogCursor(page.Logs[limit-1], sort == LOG_SORT_RANK)
+		page.NextCursor = &cursor
+	}
+	return page, nil
+}
+
+// Returns a single log, with its parsed traceback frames, if it's in a project the user is permitted on
+func (db Db) GetLog(userId string, logId string) (Log, error) {
+	if !isUuid(logId) {
+		return Log{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	row := db.Db.QueryRow(`SELECT `+LOG_SELECT_COLUMNS+`, traceback_frames
+FROM log
+WHERE id = $1
+AND project_id IN (`+readableProjects("$2")+`)`, logId, userId)
+	var frames []byte
+	log, err := scanLog(row, &frames)
+	if err == sql.ErrNoRows {
+		return Log{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	if err != nil {
+		db.Logger.Println(err)
+		return Log{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	log.TracebackFrames = frames
+	return log, nil
+}
+
+// Authenticates the api key once and inserts every valid entry in a single transaction.
+// Entries that fail validation or insertion are reported in their result rather than failing the batch.
+func (db Db) CreateLogs(userId string, entries []LogEntry, apiKey string) ([]LogResult, error) {
+	projectId, err := db.GetApiKeyProjectId(userId, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return db.WriteLogs(userId, projectId, entries)
+}
+
+// Inserts entries for a project whose api key has already been checked with GetApiKeyProjectId.
+// Used directly by callers that authenticate once and then write many batches.
+func (db Db) WriteLogs(userId string, projectId string, entries []LogEntry) ([]LogResult, error) {
+	errs, err := db.PrepareLogs(userId, projectId, entries)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]LogResult, len(entries))
+	valid := make([]LogEntry, 0, len(entries))
+	validIndexes := make([]int, 0, len(entries))
+	for i := range entries {
+		results[i].Index = i
+		if errs[i] != nil {
+			msg := errs[i].Error()
+			results[i].Error = &msg
+			continue
+		}
+		if entries[i].duplicate {
+			results[i].Id = &entries[i].LogId
+			results[i].Duplicate = true
+			continue
+		}
+		valid = append(valid, entries[i])
+		validIndexes = append(validIndexes, i)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+	inserted, err := db.InsertLogs(valid)
+	if err != nil {
+		db.ReleaseLogs(valid)
+		return nil, err
+	}
+	for i, result := range inserted {
+		result.Index = validIndexes[i]
+		results[validIndexes[i]] = result
+	}
+	return results, nil
+}
+
+// Validates entries against the project their api key was issued for and assigns their ids.
+// Entries whose idempotency key was already written are given the original id and marked as duplicates, see reserveIdempotencyKeys.
+// The returned slice holds the validation error, if any, for the entry at the same index.
+func (db Db) PrepareLogs(userId string, projectId string, entries []LogEntry) ([]error, error) {
+	levels, err := db.getProjectLevels(projectId)
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]error, len(entries))
+	receivedAt := time.Now()
+	for i := range entries {
+		errs[i] = validateLogEntry(&entries[i], projectId, levels, db.timestamps, receivedAt)
+		if errs[i] != nil {
+			continue
+		}
+		if entries[i].Process != nil {
+			processId, err := db.GetOrCreateProcessId(projectId, *entries[i].Process)
+			if err != nil && err.Error() == error_msgs.PROCESS_LIMIT {
+				// The log is still written, keeping the name it gave as an attribute
+				if entries[i].Attributes == nil {
+					entries[i].Attributes = map[string]any{}
+				}
+				entries[i].Attributes["process"] = *entries[i].Process
+			} else if err != nil {
+				errs[i] = err
+				continue
+			} else {
+				entries[i].ProcessId = &processId
+			}
+		}
+		entries[i].UserId = userId
+		entries[i].LogId, err = NewUuid()
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+	}
+	err = db.reserveIdempotencyKeys(projectId, entries, errs, receivedAt)
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// Forgets the idempotency keys of entries that were prepared but couldn't be written, so retries aren't turned away
+func (db Db) ReleaseLogs(entries []LogEntry) {
+	db.releaseIdempotencyKeys(entries)
+}
+
+// Writes prepared entries, which may belong to different users and projects, in a single transaction.
+// On error nothing was written and the entries keep their idempotency keys, so the caller can retry them or give up with ReleaseLogs.
+func (db Db) InsertLogs(entries []LogEntry) ([]LogResult, error) {
+	results, err := db.insertLogs(entries)
+	if err != nil {
+		return nil, err
+	}
+	failed := []LogEntry{}
+	for i, result := range results {
+		if result.Error != nil {
+			failed = append(failed, entries[i])
+		}
+	}
+	db.ReleaseLogs(failed)
+	return results, nil
+}
+
+func (db Db) insertLogs(entries []LogEntry) ([]LogResult, error) {
+	results := make([]LogResult, len(entries))
+	for i := range results {
+		results[i].Index = i
+	}
 	tx, err := db.Db.Begin()
 	if err != nil {
 		db.Logger.Println(err)
-		return "", errors.New(error_msgs.DATABASE_ERROR)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
 	}
-	_, err = db.getPermittedProjectIdFromApiKey(userId, apiKey)
+	for start := 0; start < len(entries); start += INSERT_CHUNK_SIZE {
+		end := min(start+INSERT_CHUNK_SIZE, len(entries))
+		err = db.insertLogChunk(tx, entries[start:end], results[start:end])
+		if err != nil {
+			innerErr := tx.Rollback()
+			if innerErr != nil {
+				db.Logger.Println(innerErr)
+			}
+			return nil, err
+		}
+	}
+	err = db.commitIdempotencyKeys(tx, entries, results)
 	if err != nil {
 		innerErr := tx.Rollback()
 		if innerErr != nil {
 			db.Logger.Println(innerErr)
-			return "", errors.New(error_msgs.DATABASE_ERROR)
 		}
-		return "", err
+		return nil, err
 	}
-	row := tx.QueryRow("INSERT INTO log (user_id, project_id, level_id, process_id, message, traceback) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", userId, project_id, level_id, process_id, message, traceback)
-	err = row.Scan(&logId)
+	err = db.recordIssues(tx, entries, results)
 	if err != nil {
-		db.Logger.Println(err)
 		innerErr := tx.Rollback()
 		if innerErr != nil {
 			db.Logger.Println(innerErr)
-			return "", errors.New(error_msgs.DATABASE_ERROR)
 		}
-		return "", errors.New(error_msgs.DATABASE_ERROR)
+		return nil, err
 	}
+	notices := writtenNotices(entries, results)
+	notified := db.notifyLogs(tx, notices)
 	err = tx.Commit()
 	if err != nil {
 		db.Logger.Println(err)
-		return "", errors.New(error_msgs.DATABASE_ERROR)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	db.touchProcesses(entries, results)
+	if !notified {
+		db.PublishLogs(notices)
 	}
-	return logId, nil
+	return results, nil
 }
 
-func (db Db) GetLogs(userId string, projectId *string, levelId *int, processId *string, orgId *string, from *time.Time, to *time.Time) ([]Log, error) {
-	var logs []Log
-	tx, err := db.Db.Begin()
+// Fills in defaults and checks an entry against the project its api key was issued for
+func validateLogEntry(entry *LogEntry, projectId string, levels projectLevels, timestamps TimestampPolicy, receivedAt time.Time) error {
+	if entry.ProjectId == "" {
+		entry.ProjectId = projectId
+	}
+	if entry.ProjectId != projectId {
+		return errors.New(error_msgs.PROJECT_MISMATCH)
+	}
+	if entry.Level != nil {
+		levelId, ok := levels.resolve(*entry.Level)
+		if ok {
+			entry.LevelId = levelId
+		} else if entry.LevelId == 0 {
+			return errors.New(error_msgs.GetInvalidMessage("level"))
+		}
+	}
+	if entry.LevelId == 0 {
+		return errors.New(error_msgs.GetRequiredMessage("level_id"))
+	}
+	if !levels.ids[entry.LevelId] {
+		return errors.New(error_msgs.GetInvalidMessage("level_id"))
+	}
+	if entry.Id != nil && (*entry.Id == "" || len(*entry.Id) > MAX_IDEMPOTENCY_KEY_LENGTH) {
+		return errors.New(error_msgs.GetInvalidMessage("id"))
+	}
+	err := validateTraceIds(entry)
+	if err != nil {
+		return err
+	}
+	if entry.Process != nil {
+		if entry.ProcessId != nil {
+			return errors.New(error_msgs.PROCESS_CONFLICT)
+		}
+		name := strings.TrimSpace(*entry.Process)
+		if name == "" || len(name) > MAX_PROCESS_NAME_LENGTH {
+			return errors.New(error_msgs.GetInvalidMessage("process"))
+		}
+		entry.Process = &name
+	}
+	if entry.Message == "" {
+		return errors.New(error_msgs.GetRequiredMessage("message"))
+	}
+	if len(entry.Attributes) > 0 {
+		attributes, err := json.Marshal(entry.Attributes)
+		if err != nil {
+			return errors.New(error_msgs.GetInvalidMessage("attributes"))
+		}
+		entry.attributesJson = attributes
+	}
+	entry.stacks = nil
+	entry.framesJson = nil
+	if entry.Traceback != nil {
+		entry.stacks = traceback.Parse(*entry.Traceback)
+		if entry.stacks != nil {
+			frames, err := json.Marshal(entry.stacks)
+			if err != nil {
+				return errors.New(error_msgs.GetInvalidMessage("traceback"))
+			}
+			entry.framesJson = frames
+		}
+	}
+	entry.fingerprint = nil
+	if levels.severities[entry.LevelId] >= ISSUE_MIN_SEVERITY {
+		fingerprint := issues.New(entry.Message, entry.stacks)
+		entry.fingerprint = &fingerprint
+	}
+	entry.receivedAt = receivedAt
+	entry.createdAt = receivedAt
+	entry.skewed = false
+	if entry.Timestamp != nil {
+		createdAt, skewed, ok := timestamps.apply(*entry.Timestamp, receivedAt)
+		if !ok {
+			return errors.New(error_msgs.TIMESTAMP_OUT_OF_RANGE)
+		}
+		entry.createdAt = createdAt
+		entry.skewed = skewed
+	}
+	return nil
+}
+
+// Inserts the entries with one multi-row statement.
+// If the statement fails the rows are retried one at a time so a single bad row only fails itself.
+func (db Db) insertLogChunk(tx *sql.Tx, entries []LogEntry, results []LogResult) error {
+	_, err := tx.Exec("SAVEPOINT log_chunk")
 	if err != nil {
 		db.Logger.Println(err)
-		return nil, errors.New(error_msgs.DATABASE_ERROR)
+		return errors.New(error_msgs.DATABASE_ERROR)
 	}
-	var rows *sql.Rows
-	query := "SELECT * FROM log WHERE user_id = $1"
-	variableIndex := 2
+	query := "INSERT INTO log (" + LOG_INSERT_COLUMNS + ") VALUES "
 	args := make([]any, 0)
-	args = append(args, userId)
-	// TODO: I kind of hate this
-	if projectId != nil {
-		query += fmt.Sprintf(" AND project_id = $%d", variableIndex)
-		args = append(args, *projectId)
-		variableIndex++
-	}
-	if levelId != nil {
-		query += fmt.Sprintf(" AND level_id = $%d", variableIndex)
-		args = append(args, *levelId)
-		variableIndex++
-	}
-	if processId != nil {
-		query += fmt.Sprintf(" AND process_id = $%d", variableIndex)
-		args = append(args, *processId)
-		variableIndex++
-	}
-	if orgId != nil {
-		query += fmt.Sprintf(" AND org_id = $%d", variableIndex)
-		args = append(args, *orgId)
-		variableIndex++
-	}
-	if from != nil {
-		query += fmt.Sprintf(" AND created_at >= $%d", variableIndex)
-		args = append(args, *from)
-		variableIndex++
-	}
-	if to != nil {
-		query += fmt.Sprintf(" AND created_at <= $%d", variableIndex)
-		args = append(args, *to)
-		variableIndex++
-	}
-	rows, err = tx.Query(query, args...)
+	for i, entry := range entries {
+		if i > 0 {
+			query += ", "
+		}
+		row := entry.insertArgs()
+		query += "(" + placeholders(len(args)+1, len(row)) + ")"
+		args = append(args, row...)
+	}
+	_, err = tx.Exec(query, args...)
+	if err == nil {
+		for i := range entries {
+			results[i].Id = &entries[i].LogId
+		}
+		return nil
+	}
+	db.Logger.Println(err)
+	_, err = tx.Exec("ROLLBACK TO SAVEPOINT log_chunk")
 	if err != nil {
 		db.Logger.Println(err)
-		innerErr := tx.Rollback()
-		if innerErr != nil {
-			db.Logger.Println(innerErr)
-			return nil, errors.New(error_msgs.DATABASE_ERROR)
-		}
-		return nil, errors.New(error_msgs.DATABASE_ERROR)
+		return errors.New(error_msgs.DATABASE_ERROR)
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var log Log
-		err = rows.Scan(&log.Id, &log.CreatedAt, &log.UserId, &log.ProjectId, &log.LevelId, &log.ProcessId, &log.Message, &log.Traceback)
+	for i, entry := range entries {
+		_, err = tx.Exec("SAVEPOINT log_row")
 		if err != nil {
 			db.Logger.Println(err)
-			innerErr := tx.Rollback()
-			if innerErr != nil {
-				db.Logger.Println(innerErr)
-				return nil, errors.New(error_msgs.DATABASE_ERROR)
+			return errors.New(error_msgs.DATABASE_ERROR)
+		}
+		row := entry.insertArgs()
+		_, err = tx.Exec("INSERT INTO log ("+LOG_INSERT_COLUMNS+") VALUES ("+placeholders(1, len(row))+")", row...)
+		if err != nil {
+			db.Logger.Println(err)
+			msg := error_msgs.DATABASE_ERROR
+			if strings.Contains(err.Error(), "process_id") {
+				msg = error_msgs.GetInvalidMessage("process_id")
 			}
-			return nil, errors.New(error_msgs.DATABASE_ERROR)
+			results[i].Error = &msg
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT log_row")
+			if err != nil {
+				db.Logger.Println(err)
+				return errors.New(error_msgs.DATABASE_ERROR)
+			}
+			continue
 		}
-		logs = append(logs, log)
+		results[i].Id = &entries[i].LogId
 	}
-	return logs, nil
+	return nil
 }
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Most neighbouring logs that can be read on either side of a log
+const MAX_LOG_CONTEXT = 100
+
+// A log with the names of what it refers to, and the logs around it from the same process
+type LogDetail struct {
+	Log
+	ProjectName string  `json:"project_name"`
+	ProcessName *string `json:"process_name"`
+	LevelName   string  `json:"level_name"`
+	// Logs from the same process leading up to the log and following it, oldest first
+	Before []Log `json:"before"`
+	After  []Log `json:"after"`
+}
+
+// Returns a log with its project, process and level names, like GetLog, along with up to before and after
+// of the logs around it from the same process. Logs without a process are surrounded by the project's other logs without one.
+func (db Db) GetLogDetail(userId string, logId string, before int, after int) (LogDetail, error) {
+	if before < 0 || before > MAX_LOG_CONTEXT {
+		return LogDetail{}, errors.New(error_msgs.GetInvalidMessage("before"))
+	}
+	if after < 0 || after > MAX_LOG_CONTEXT {
+		return LogDetail{}, errors.New(error_msgs.GetInvalidMessage("after"))
+	}
+	log, err := db.GetLog(userId, logId)
+	if err != nil {
+		return LogDetail{}, err
+	}
+	detail := LogDetail{Log: log, Before: []Log{}, After: []Log{}}
+	err = db.Db.QueryRow(`SELECT project.name, process.name, log_level.value
+FROM project
+LEFT JOIN process ON process.id = $2
+LEFT JOIN log_level ON log_level.id = $3
+WHERE project.id = $1`, log.ProjectId, log.ProcessId, log.LevelId).Scan(&detail.ProjectName, &detail.ProcessName, &detail.LevelName)
+	if err != nil {
+		db.Logger.Println(err)
+		return LogDetail{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	if before > 0 {
+		detail.Before, err = db.getLogContext(log, "<", "DESC", before)
+		if err != nil {
+			return LogDetail{}, err
+		}
+		slices.Reverse(detail.Before)
+	}
+	if after > 0 {
+		detail.After, err = db.getLogContext(log, ">", "ASC", after)
+		if err != nil {
+			return LogDetail{}, err
+		}
+	}
+	return detail, nil
+}
+
+// Reads up to limit logs of the log's process on one side of it, nearest first
+func (db Db) getLogContext(log Log, comparison string, direction string, limit int) ([]Log, error) {
+	// IS NOT DISTINCT FROM can't use an index, so logs without a process are matched separately
+	process := "process_id = $2"
+	if log.ProcessId == nil {
+		process = "$2::uuid IS NULL AND process_id IS NULL"
+	}
+	rows, err := db.Db.Query(`SELECT `+LOG_SELECT_COLUMNS+`
+FROM log
+WHERE project_id = $1
+AND `+process+`
+AND (created_at, id) `+comparison+` ($3, $4)
+ORDER BY created_at `+direction+`, id `+direction+`
+LIMIT $5`, log.ProjectId, log.ProcessId, log.CreatedAt, log.Id, limit)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	logs := []Log{}
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		logs = append(logs, log)
+	}
+	err = rows.Err()
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return logs, nil
+}
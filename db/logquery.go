@@ -0,0 +1,162 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/logquery"
+	"github.com/jesses-code-adventures/every_log/search"
+	"github.com/lib/pq"
+)
+
+// Matches logs whose level, built in or defined by the log's project, has one of the upper cased names
+func levelNameCondition(names string) string {
+	return "level_id IN (SELECT id FROM log_level WHERE (log_level.project_id IS NULL OR log_level.project_id = log.project_id) AND upper(value) = ANY(" + names + "))"
+}
+
+// Matches logs whose level's severity compares to the severity of the named level in the log's project
+func levelSeverityCondition(operator string, names string) string {
+	return fmt.Sprintf(`level_id IN (SELECT id FROM log_level WHERE (log_level.project_id IS NULL OR log_level.project_id = log.project_id) AND severity %s (
+SELECT max(named.severity) FROM log_level AS named WHERE (named.project_id IS NULL OR named.project_id = log.project_id) AND upper(named.value) = ANY(%s)))`, operator, names)
+}
+
+var severityOperators = map[string]string{
+	logquery.OP_GT:  ">",
+	logquery.OP_GTE: ">=",
+	logquery.OP_LT:  "<",
+	logquery.OP_LTE: "<=",
+}
+
+var attributeOps = map[string]string{
+	logquery.OP_GT:  ATTRIBUTE_GT,
+	logquery.OP_GTE: ATTRIBUTE_GTE,
+	logquery.OP_LT:  ATTRIBUTE_LT,
+	logquery.OP_LTE: ATTRIBUTE_LTE,
+}
+
+// Compiles a parsed log query into a condition on the log table, adding its values to args.
+// Errors point at the part of the query that caused them.
+func compileLogQuery(node logquery.Node, args *queryArgs) (string, error) {
+	switch n := node.(type) {
+	case logquery.And:
+		return compileLogQueries(n.Nodes, " AND ", args)
+	case logquery.Or:
+		return compileLogQueries(n.Nodes, " OR ", args)
+	case logquery.Not:
+		condition, err := compileLogQuery(n.Node, args)
+		if err != nil {
+			return "", err
+		}
+		return negate(condition), nil
+	case logquery.Text:
+		tsquery, err := search.ToTsquery(n.Raw)
+		if err != nil {
+			var syntaxErr search.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				syntaxErr.Position += n.Position
+				return "", queryError(syntaxErr.Message, syntaxErr.Position)
+			}
+			return "", queryError(err.Error(), n.Position)
+		}
+		return fmt.Sprintf("search_vector @@ to_tsquery('%s', %s)", SEARCH_CONFIG, args.add(tsquery)), nil
+	case logquery.Field:
+		if n.Op == logquery.OP_NE {
+			n.Op = logquery.OP_EQ
+			condition, err := compileField(n, args)
+			if err != nil {
+				return "", err
+			}
+			return negate(condition), nil
+		}
+		return compileField(n, args)
+	}
+	return "", errors.New(error_msgs.GetSearchErrorMessage("unsupported query"))
+}
+
+func compileLogQueries(nodes []logquery.Node, operator string, args *queryArgs) (string, error) {
+	conditions := make([]string, len(nodes))
+	for i, node := range nodes {
+		condition, err := compileLogQuery(node, args)
+		if err != nil {
+			return "", err
+		}
+		conditions[i] = condition
+	}
+	return "(" + strings.Join(conditions, operator) + ")", nil
+}
+
+// Negates a condition so logs where it's NULL, such as logs without a process for -process:worker, match too
+func negate(condition string) string {
+	return "NOT COALESCE((" + condition + "), FALSE)"
+}
+
+func queryError(message string, position int) error {
+	return errors.New(error_msgs.GetSearchErrorMessage(logquery.SyntaxError{Message: message, Position: position}.Error()))
+}
+
+// Compiles a field comparison, with != already turned into a negated :
+func compileField(field logquery.Field, args *queryArgs) (string, error) {
+	switch field.Name {
+	case logquery.FIELD_LEVEL:
+		if operator, ok := severityOperators[field.Op]; ok {
+			return levelSeverityCondition(operator, args.add(pq.Array(levelNames(field.Value)))), nil
+		}
+		if id, err := strconv.Atoi(field.Value); err == nil {
+			return "level_id = " + args.add(id), nil
+		}
+		return levelNameCondition(args.add(pq.Array(levelNames(field.Value)))), nil
+	case logquery.FIELD_PROCESS:
+		if !field.Prefix && isUuid(field.Value) {
+			return "process_id = " + args.add(field.Value), nil
+		}
+		return "process_id IN (SELECT id FROM process WHERE process.project_id = log.project_id AND " + nameCondition("process.name", field, args) + ")", nil
+	case logquery.FIELD_PROJECT:
+		if !field.Prefix && isUuid(field.Value) {
+			return "project_id = " + args.add(field.Value), nil
+		}
+		return "project_id IN (SELECT id FROM project WHERE " + nameCondition("project.name", field, args) + ")", nil
+	case logquery.FIELD_TRACE:
+		if !ValidTraceId(strings.ToLower(field.Value)) {
+			return "", queryError("trace needs a 32 character hex trace id", field.ValuePosition)
+		}
+		return "trace_id = " + args.add(strings.ToLower(field.Value)), nil
+	case logquery.FIELD_SPAN:
+		if !ValidSpanId(strings.ToLower(field.Value)) {
+			return "", queryError("span needs a 16 character hex span id", field.ValuePosition)
+		}
+		return "span_id = " + args.add(strings.ToLower(field.Value)), nil
+	case logquery.FIELD_ISSUE:
+		if !isUuid(field.Value) {
+			return "", queryError("issue needs an issue id", field.ValuePosition)
+		}
+		return "issue_id = " + args.add(field.Value), nil
+	case logquery.FIELD_SINCE:
+		return "created_at >= " + args.add(field.Time), nil
+	case logquery.FIELD_UNTIL:
+		return "created_at <= " + args.add(field.Time), nil
+	case logquery.FIELD_ATTR:
+		filter := AttributeFilter{Key: field.Key, Op: ATTRIBUTE_EQ, Value: field.Json}
+		if field.Prefix {
+			filter.Op = ATTRIBUTE_EXISTS
+		} else if op, ok := attributeOps[field.Op]; ok {
+			filter.Op = op
+		}
+		condition, err := filter.condition(args)
+		if err != nil {
+			return "", queryError(err.Error(), field.Position)
+		}
+		return condition, nil
+	}
+	return "", queryError("unknown field "+field.Name, field.Position)
+}
+
+// Matches a name exactly, or by prefix for a value ending in *
+func nameCondition(column string, field logquery.Field, args *queryArgs) string {
+	if field.Prefix {
+		return column + " LIKE " + args.add(escapeLike(field.Value)+"%")
+	}
+	return column + " = " + args.add(field.Value)
+}
@@ -1,7 +1,6 @@
 package db
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
@@ -75,60 +74,41 @@ func (db Db) CreateOrg(userId string, name string, description *string, location
 	return orgId, nil
 }
 
+// Lists the orgs the user is a member of, optionally narrowed to one org, a name pattern or a creation window
 func (db Db) GetOrgs(userId string, orgId *string, name *string, from *time.Time, to *time.Time) ([]Org, error) {
-	var orgs []Org
-	tx, err := db.Db.Begin()
-	if err != nil {
-		db.Logger.Println(err)
-		return nil, errors.New(error_msgs.DATABASE_ERROR)
-	}
-	var rows *sql.Rows
-	query := "SELECT org.id, org.created_at, org.name, org.description, org.location_id FROM org INNER JOIN user_org WHERE user_org.user_id = $1"
-	variableIndex := 2
-	args := make([]any, 0)
-	args = append(args, userId)
-	// TODO: I kind of hate this
+	args := queryArgs{values: []any{userId}}
+	conditions := []string{"user_org.user_id = $1"}
 	if orgId != nil {
-		query += fmt.Sprintf(" AND project_id = $%d", variableIndex)
-		args = append(args, *orgId)
-		variableIndex++
+		if !isUuid(*orgId) {
+			return nil, errors.New(error_msgs.GetInvalidMessage("org_id"))
+		}
+		conditions = append(conditions, "org.id = "+args.add(*orgId))
 	}
 	if name != nil {
-		query += fmt.Sprintf(" AND name LIKE $%d", variableIndex)
-		args = append(args, *name)
-		variableIndex++
+		conditions = append(conditions, "org.name LIKE "+args.add(*name))
 	}
 	if from != nil {
-		query += fmt.Sprintf(" AND created_at >= $%d", variableIndex)
-		args = append(args, *from)
-		variableIndex++
+		conditions = append(conditions, "org.created_at >= "+args.add(*from))
 	}
 	if to != nil {
-		query += fmt.Sprintf(" AND created_at <= $%d", variableIndex)
-		args = append(args, *to)
-		variableIndex++
+		conditions = append(conditions, "org.created_at <= "+args.add(*to))
 	}
-	rows, err = tx.Query(query, args...)
+	rows, err := db.Db.Query(`SELECT org.id, org.created_at, org.name, org.description, org.location_id
+FROM org
+INNER JOIN user_org ON user_org.org_id = org.id
+WHERE `+allOf(conditions)+`
+ORDER BY org.name, org.id`, args.values...)
 	if err != nil {
 		db.Logger.Println(err)
-		innerErr := tx.Rollback()
-		if innerErr != nil {
-			db.Logger.Println(innerErr)
-			return nil, errors.New(error_msgs.DATABASE_ERROR)
-		}
 		return nil, errors.New(error_msgs.DATABASE_ERROR)
 	}
 	defer rows.Close()
+	orgs := []Org{}
 	for rows.Next() {
 		var org Org
 		err = rows.Scan(&org.Id, &org.CreatedAt, &org.Name, &org.Description, &org.LocationId)
 		if err != nil {
 			db.Logger.Println(err)
-			innerErr := tx.Rollback()
-			if innerErr != nil {
-				db.Logger.Println(innerErr)
-				return nil, errors.New(error_msgs.DATABASE_ERROR)
-			}
 			return nil, errors.New(error_msgs.DATABASE_ERROR)
 		}
 		orgs = append(orgs, org)
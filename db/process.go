@@ -0,0 +1,163 @@
+package db
+
+import (
+	"container/list"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/lib/pq"
+)
+
+// Matches the process.name column
+const MAX_PROCESS_NAME_LENGTH = 255
+
+type Process struct {
+	Id          string     `json:"id"`
+	ProjectId   string     `json:"project_id"`
+	Name        string     `json:"name"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  *time.Time `json:"last_seen_at"`
+}
+
+// Most processes a project can have. Ingestion paths such as syslog name processes after whatever the sender says,
+// so this bounds what an unauthenticated or misbehaving sender can add. Logs naming a new process past it are written without one.
+const MAX_PROCESSES_PER_PROJECT = 1000
+
+// Most process ids cached across every project, the least recently used are evicted
+const PROCESS_CACHE_SIZE = 10000
+
+type processKey struct {
+	projectId string
+	name      string
+}
+
+// Process ids by project and name, so ingestion only touches the process table the first time a name is seen.
+// Processes are never renamed or deleted, so cached ids don't go stale.
+type processCache struct {
+	mu      sync.Mutex
+	size    int
+	ids     map[processKey]*list.Element
+	recency *list.List
+}
+
+type cachedProcess struct {
+	key processKey
+	id  string
+}
+
+func newProcessCache(size int) *processCache {
+	return &processCache{size: size, ids: map[processKey]*list.Element{}, recency: list.New()}
+}
+
+func (c *processCache) get(key processKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.ids[key]
+	if !ok {
+		return "", false
+	}
+	c.recency.MoveToFront(element)
+	return element.Value.(cachedProcess).id, true
+}
+
+func (c *processCache) set(key processKey, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.ids[key]; ok {
+		c.recency.MoveToFront(element)
+		return
+	}
+	c.ids[key] = c.recency.PushFront(cachedProcess{key: key, id: id})
+	for c.recency.Len() > c.size {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.ids, oldest.Value.(cachedProcess).key)
+	}
+}
+
+// Returns the id of a named process in a project, creating the process the first time it's seen.
+// Returns PROCESS_LIMIT when the process is new and the project already has MAX_PROCESSES_PER_PROJECT.
+func (db Db) GetOrCreateProcessId(projectId string, name string) (string, error) {
+	key := processKey{projectId: projectId, name: name}
+	if db.processes != nil {
+		if id, ok := db.processes.get(key); ok {
+			return id, nil
+		}
+	}
+	var processId string
+	err := db.Db.QueryRow("SELECT id FROM process WHERE project_id = $1 AND name = $2", projectId, name).Scan(&processId)
+	if err == sql.ErrNoRows {
+		// Concurrent writers can take a project slightly past the limit, which only needs to be approximate
+		err = db.Db.QueryRow(`INSERT INTO process (project_id, name)
+SELECT $1, $2
+WHERE (SELECT count(*) FROM process WHERE project_id = $1) < $3
+ON CONFLICT (name, project_id) DO UPDATE SET name = EXCLUDED.name
+RETURNING id`, projectId, name, MAX_PROCESSES_PER_PROJECT).Scan(&processId)
+		if err == sql.ErrNoRows {
+			// A project at the limit inserts nothing, even when a concurrent writer has just created this process
+			err = db.Db.QueryRow("SELECT id FROM process WHERE project_id = $1 AND name = $2", projectId, name).Scan(&processId)
+			if err == sql.ErrNoRows {
+				return "", errors.New(error_msgs.PROCESS_LIMIT)
+			}
+		}
+	}
+	if err != nil {
+		db.Logger.Println(err)
+		return "", errors.New(error_msgs.DATABASE_ERROR)
+	}
+	if db.processes != nil {
+		db.processes.set(key, processId)
+	}
+	return processId, nil
+}
+
+// Lists a project's processes for a user permitted to see it, most recently seen first
+func (db Db) GetProcesses(userId string, projectId string) ([]Process, error) {
+	err := db.canReadProject(userId, projectId)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Db.Query(`SELECT id, project_id, name, created_at, last_seen_at
+FROM process
+WHERE project_id = $1
+ORDER BY last_seen_at DESC NULLS LAST, name`, projectId)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	processes := []Process{}
+	for rows.Next() {
+		var process Process
+		err = rows.Scan(&process.Id, &process.ProjectId, &process.Name, &process.FirstSeenAt, &process.LastSeenAt)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		processes = append(processes, process)
+	}
+	return processes, nil
+}
+
+// Marks the processes of a written batch as seen. Best effort, a failure here shouldn't fail logs that were already committed.
+func (db Db) touchProcesses(entries []LogEntry, results []LogResult) {
+	seen := map[string]bool{}
+	ids := []string{}
+	for i, entry := range entries {
+		if entry.ProcessId == nil || results[i].Id == nil || seen[*entry.ProcessId] {
+			continue
+		}
+		seen[*entry.ProcessId] = true
+		ids = append(ids, *entry.ProcessId)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	_, err := db.Db.Exec("UPDATE process SET last_seen_at = CURRENT_TIMESTAMP WHERE id = ANY($1::uuid[])", pq.Array(ids))
+	if err != nil {
+		db.Logger.Println(err)
+	}
+}
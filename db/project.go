@@ -7,10 +7,43 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jesses-code-adventures/every_log/error_msgs"
 )
 
+type Project struct {
+	Id          string    `json:"id"`
+	UserId      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Lists the projects the user can read, directly or through an org, by name
+func (db Db) GetProjects(userId string) ([]Project, error) {
+	rows, err := db.Db.Query(`SELECT id, user_id, name, description, created_at
+FROM project
+WHERE id IN (`+readableProjects("$1")+`)
+ORDER BY name, id`, userId)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	projects := []Project{}
+	for rows.Next() {
+		var project Project
+		err = rows.Scan(&project.Id, &project.UserId, &project.Name, &project.Description, &project.CreatedAt)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
 func (db Db) getPermittedProjectIdFromUserProject(userId string, project_id string) (string, error) {
 	var permittedProjectId string
 	err := db.Db.QueryRow(`SELECT permitted_project_id
@@ -41,14 +74,65 @@ func (db Db) getPermittedProjectId(userId string, projectId string, tx *sql.Tx)
 	return id, nil
 }
 
-func (db Db) getPermittedProjectIdFromApiKey(userId string, apiKey string) (string, error) {
-	var id string
+// Subquery of the projects a user can read: those they're permitted on directly and those shared with an org they belong to.
+// user is the placeholder of the user's id, eg "$1".
+func readableProjects(user string) string {
+	return `SELECT project_id FROM permitted_project WHERE permitted_project.user_id = ` + user + `
+UNION SELECT project_org.project_id FROM project_org INNER JOIN user_org ON user_org.org_id = project_org.org_id WHERE user_org.user_id = ` + user
+}
+
+// Checks the user can read the project's logs, either directly or through an org
+func (db Db) canReadProject(userId string, projectId string) error {
+	if !isUuid(projectId) {
+		return errors.New(error_msgs.UNAUTHORIZED)
+	}
+	var readable bool
+	err := db.Db.QueryRow("SELECT $2::uuid IN ("+readableProjects("$1")+")", userId, projectId).Scan(&readable)
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	if !readable {
+		return errors.New(error_msgs.UNAUTHORIZED)
+	}
+	return nil
+}
+
+// Ids of the projects the user can read, see readableProjects
+func (db Db) GetReadableProjectIds(userId string) ([]string, error) {
+	rows, err := db.Db.Query(readableProjects("$1"), userId)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Returns the project an api key was issued for, ensuring the key belongs to the user.
+// Every check of an api key goes through here.
+func (db Db) GetApiKeyProjectId(userId string, apiKey string) (string, error) {
+	var projectId string
 	var matchingUserId string
-	err := db.Db.QueryRow(`SELECT api_key.permitted_project_id, permitted_project.user_id
+	err := db.Db.QueryRow(`SELECT permitted_project.project_id, permitted_project.user_id
 FROM api_key
-LEFT JOIN permitted_project
+INNER JOIN permitted_project
 ON permitted_project.id = api_key.permitted_project_id
-WHERE api_key.key = $1;`, apiKey).Scan(&id, &matchingUserId)
+WHERE api_key.key = $1;`, apiKey).Scan(&projectId, &matchingUserId)
+	if err == sql.ErrNoRows {
+		db.Logger.Println("Api key not found")
+		return "", errors.New(error_msgs.UNAUTHORIZED)
+	}
 	if err != nil {
 		db.Logger.Println(err)
 		return "", errors.New(error_msgs.DATABASE_ERROR)
@@ -57,7 +141,7 @@ WHERE api_key.key = $1;`, apiKey).Scan(&id, &matchingUserId)
 		db.Logger.Println("User ID does not match")
 		return "", errors.New(error_msgs.UNAUTHORIZED)
 	}
-	return id, nil
+	return projectId, nil
 }
 
 // GenerateRandomAPIKey generates a random alphanumeric API key of the given length
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A query's arguments, handing out their placeholders in order so conditions can be written without counting them
+type queryArgs struct {
+	values []any
+}
+
+// Adds an argument, returning its placeholder
+func (a *queryArgs) add(value any) string {
+	a.values = append(a.values, value)
+	return fmt.Sprintf("$%d", len(a.values))
+}
+
+// ANDs conditions, matching everything when there are none
+func allOf(conditions []string) string {
+	if len(conditions) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(conditions, " AND ")
+}
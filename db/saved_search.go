@@ -0,0 +1,307 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/logquery"
+	"github.com/lib/pq"
+)
+
+const MAX_SAVED_SEARCH_NAME_LENGTH = 255
+const MAX_SAVED_SEARCH_COLUMNS = 50
+
+// The filters of GET /log a saved search keeps besides its query, search and range, named after their query parameters
+type SearchFilters struct {
+	ProjectIds []string          `json:"project_id,omitempty"`
+	Levels     []string          `json:"level,omitempty"`
+	LevelIds   []int             `json:"level_id,omitempty"`
+	ProcessIds []string          `json:"process_id,omitempty"`
+	IssueIds   []string          `json:"issue_id,omitempty"`
+	OrgIds     []string          `json:"org_id,omitempty"`
+	TraceIds   []string          `json:"trace_id,omitempty"`
+	Attributes []AttributeFilter `json:"attributes,omitempty"`
+	Match      string            `json:"match,omitempty"`
+	Similarity float64           `json:"similarity,omitempty"`
+	Sort       string            `json:"sort,omitempty"`
+}
+
+// A named view of the logs. Only its owner sees a private search, one with a project_id or org_id
+// is shared with everyone who can read the project or is in the org. Only the owner can change or delete it.
+type SavedSearch struct {
+	Id          string        `json:"id"`
+	UserId      string        `json:"user_id"`
+	ProjectId   *string       `json:"project_id"`
+	OrgId       *string       `json:"org_id"`
+	Name        string        `json:"name"`
+	Description *string       `json:"description"`
+	Filters     SearchFilters `json:"filters"`
+	// In the log query language, see logquery.Parse
+	Query string `json:"query"`
+	// Full text search, matched as Filters.Match says
+	Search string `json:"search"`
+	// A time range expression resolved each time the search runs, see logquery.ParseRange. Empty searches all time.
+	Range string `json:"range"`
+	// The log view's column layout, in order. Stored as given for clients to interpret.
+	Columns   []string  `json:"columns"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// A page of a saved search's logs, with the range it was resolved to so later pages can pin it
+type SearchResults struct {
+	Search SavedSearch `json:"search"`
+	From   *time.Time  `json:"from"`
+	To     *time.Time  `json:"to"`
+	LogPage
+}
+
+// Resolves the search's range against now, returning the filter it runs with
+func (search SavedSearch) Filter(now time.Time) (LogFilter, error) {
+	filter := LogFilter{
+		ProjectIds: search.Filters.ProjectIds,
+		Levels:     search.Filters.Levels,
+		LevelIds:   search.Filters.LevelIds,
+		ProcessIds: search.Filters.ProcessIds,
+		IssueIds:   search.Filters.IssueIds,
+		OrgIds:     search.Filters.OrgIds,
+		TraceIds:   search.Filters.TraceIds,
+		Attributes: search.Filters.Attributes,
+		Match:      search.Filters.Match,
+		Similarity: search.Filters.Similarity,
+		Sort:       search.Filters.Sort,
+		Query:      search.Query,
+		Search:     search.Search,
+	}
+	if search.Range != "" {
+		from, to, ok := logquery.ParseRange(search.Range, now)
+		if !ok {
+			return filter, errors.New(error_msgs.GetInvalidMessage("range"))
+		}
+		filter.From = &from
+		filter.To = to
+	}
+	return filter, nil
+}
+
+const SAVED_SEARCH_SELECT_COLUMNS = "id, user_id, project_id, org_id, name, description, filters, query, search, time_range, columns, created_at, updated_at"
+
+func scanSavedSearch(row interface{ Scan(dest ...any) error }) (SavedSearch, error) {
+	var search SavedSearch
+	var filters []byte
+	err := row.Scan(&search.Id, &search.UserId, &search.ProjectId, &search.OrgId, &search.Name, &search.Description, &filters,
+		&search.Query, &search.Search, &search.Range, pq.Array(&search.Columns), &search.CreatedAt, &search.UpdatedAt)
+	if err != nil {
+		return search, err
+	}
+	if search.Columns == nil {
+		search.Columns = []string{}
+	}
+	err = json.Unmarshal(filters, &search.Filters)
+	return search, err
+}
+
+// A condition on saved_search limiting it to the searches user can see, theirs and those shared with them
+func readableSearches(user string) string {
+	return "(saved_search.user_id = " + user + " OR saved_search.project_id IN (" + readableProjects(user) + ") OR saved_search.org_id IN (SELECT org_id FROM user_org WHERE user_id = " + user + "))"
+}
+
+// Checks a search the user is about to save, that it runs and that they can share it where it's shared
+func (db Db) checkSavedSearch(userId string, search SavedSearch) error {
+	if search.Name == "" {
+		return errors.New(error_msgs.GetRequiredMessage("name"))
+	}
+	if len(search.Name) > MAX_SAVED_SEARCH_NAME_LENGTH {
+		return errors.New(error_msgs.GetInvalidMessage("name"))
+	}
+	if len(search.Columns) > MAX_SAVED_SEARCH_COLUMNS {
+		return errors.New(error_msgs.GetInvalidMessage("columns"))
+	}
+	for _, column := range search.Columns {
+		if column == "" {
+			return errors.New(error_msgs.GetInvalidMessage("columns"))
+		}
+	}
+	filter, err := search.Filter(time.Now())
+	if err != nil {
+		return err
+	}
+	if filter.Sort != "" && filter.Sort != LOG_SORT_TIME && (filter.Sort != LOG_SORT_RANK || filter.Search == "") {
+		return errors.New(error_msgs.GetInvalidMessage("sort"))
+	}
+	_, _, _, err = filter.conditions(userId)
+	if err != nil {
+		return err
+	}
+	if search.ProjectId != nil && search.OrgId != nil {
+		return errors.New(error_msgs.SHARE_CONFLICT)
+	}
+	if search.ProjectId != nil {
+		return db.canReadProject(userId, *search.ProjectId)
+	}
+	if search.OrgId != nil {
+		if !isUuid(*search.OrgId) {
+			return errors.New(error_msgs.UNAUTHORIZED)
+		}
+		orgs, err := db.GetOrgs(userId, search.OrgId, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		if len(orgs) == 0 {
+			return errors.New(error_msgs.UNAUTHORIZED)
+		}
+	}
+	return nil
+}
+
+// Lists the searches the user saved or that are shared with them, by name
+func (db Db) GetSavedSearches(userId string) ([]SavedSearch, error) {
+	rows, err := db.Db.Query(`SELECT `+SAVED_SEARCH_SELECT_COLUMNS+`
+FROM saved_search
+WHERE `+readableSearches("$1")+`
+ORDER BY name, id`, userId)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	searches := []SavedSearch{}
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		searches = append(searches, search)
+	}
+	return searches, nil
+}
+
+// Returns a saved search if the user saved it or it's shared with them
+func (db Db) GetSavedSearch(userId string, searchId string) (SavedSearch, error) {
+	if !isUuid(searchId) {
+		return SavedSearch{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	row := db.Db.QueryRow(`SELECT `+SAVED_SEARCH_SELECT_COLUMNS+`
+FROM saved_search
+WHERE id = $2
+AND `+readableSearches("$1"), userId, searchId)
+	search, err := scanSavedSearch(row)
+	if err == sql.ErrNoRows {
+		return SavedSearch{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	if err != nil {
+		db.Logger.Println(err)
+		return SavedSearch{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return search, nil
+}
+
+// Saves a search owned by the user, returning it as saved
+func (db Db) CreateSavedSearch(userId string, search SavedSearch) (SavedSearch, error) {
+	err := db.checkSavedSearch(userId, search)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	filters, err := json.Marshal(search.Filters)
+	if err != nil {
+		db.Logger.Println(err)
+		return SavedSearch{}, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	row := db.Db.QueryRow(`INSERT INTO saved_search (user_id, project_id, org_id, name, description, filters, query, search, time_range, columns)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING `+SAVED_SEARCH_SELECT_COLUMNS, userId, search.ProjectId, search.OrgId, search.Name, search.Description, filters,
+		search.Query, search.Search, search.Range, pq.Array(columnsOrEmpty(search.Columns)))
+	search, err = scanSavedSearch(row)
+	if err != nil {
+		db.Logger.Println(err)
+		return SavedSearch{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return search, nil
+}
+
+// Replaces everything but the owner of one of the user's saved searches, returning it as saved
+func (db Db) UpdateSavedSearch(userId string, searchId string, search SavedSearch) (SavedSearch, error) {
+	if !isUuid(searchId) {
+		return SavedSearch{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	err := db.checkSavedSearch(userId, search)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	filters, err := json.Marshal(search.Filters)
+	if err != nil {
+		db.Logger.Println(err)
+		return SavedSearch{}, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	row := db.Db.QueryRow(`UPDATE saved_search
+SET project_id = $3, org_id = $4, name = $5, description = $6, filters = $7, query = $8, search = $9, time_range = $10, columns = $11,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+AND user_id = $1
+RETURNING `+SAVED_SEARCH_SELECT_COLUMNS, userId, searchId, search.ProjectId, search.OrgId, search.Name, search.Description, filters,
+		search.Query, search.Search, search.Range, pq.Array(columnsOrEmpty(search.Columns)))
+	search, err = scanSavedSearch(row)
+	if err == sql.ErrNoRows {
+		return SavedSearch{}, errors.New(error_msgs.NOT_FOUND)
+	}
+	if err != nil {
+		db.Logger.Println(err)
+		return SavedSearch{}, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	return search, nil
+}
+
+// Deletes one of the user's saved searches
+func (db Db) DeleteSavedSearch(userId string, searchId string) error {
+	if !isUuid(searchId) {
+		return errors.New(error_msgs.NOT_FOUND)
+	}
+	result, err := db.Db.Exec("DELETE FROM saved_search WHERE id = $1 AND user_id = $2", searchId, userId)
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		db.Logger.Println(err)
+		return errors.New(error_msgs.DATABASE_ERROR)
+	}
+	if deleted == 0 {
+		return errors.New(error_msgs.NOT_FOUND)
+	}
+	return nil
+}
+
+// Returns a page of a saved search's logs, see GetLogs. from and to replace the search's range when set.
+func (db Db) RunSavedSearch(userId string, searchId string, from *time.Time, to *time.Time, limit int, cursor string) (SearchResults, error) {
+	search, err := db.GetSavedSearch(userId, searchId)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	filter, err := search.Filter(time.Now())
+	if err != nil {
+		return SearchResults{}, err
+	}
+	if from != nil || to != nil {
+		filter.From = from
+		filter.To = to
+	}
+	filter.Limit = limit
+	filter.Cursor = cursor
+	page, err := db.GetLogs(userId, filter)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	return SearchResults{Search: search, From: filter.From, To: filter.To, LogPage: page}, nil
+}
+
+func columnsOrEmpty(columns []string) []string {
+	if columns == nil {
+		return []string{}
+	}
+	return columns
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/search"
+)
+
+// Text search configuration of log.search_vector. Searches have to be parsed with the same configuration to match.
+const SEARCH_CONFIG = "english"
+
+// Orders of GetLogs. Ranking is only possible with a full text or fuzzy search.
+const (
+	LOG_SORT_TIME = "time"
+	LOG_SORT_RANK = "rank"
+)
+
+// How LogFilter.Search is matched. Full text searches message and traceback words, the others use
+// the trigram index on message so they also find partial words such as the start of a hex id.
+const (
+	MATCH_FULLTEXT  = "fulltext"
+	MATCH_FUZZY     = "fuzzy"
+	MATCH_SUBSTRING = "substring"
+	MATCH_REGEX     = "regex"
+)
+
+// How closely a fuzzy search has to match some part of a message, from 0 to 1
+const DEFAULT_SIMILARITY_THRESHOLD = 0.4
+
+// Upper bound on fuzzy and regex searches, which can fall back to scanning messages
+const SEARCH_STATEMENT_TIMEOUT = "10s"
+
+// Marks matches with <mark> tags. Message text isn't escaped, so snippets must be rendered as text around the marks rather than as html.
+const SEARCH_HEADLINE_OPTIONS = `StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "`
+
+// Snippets of a log that matched a search, with the matching words marked
+type LogHighlight struct {
+	Message string `json:"message"`
+	// Only set when the traceback matched
+	Traceback *string `json:"traceback"`
+}
+
+// A search compiled to sql for GetLogs. Its arguments are always numbered from $2 so they can be referred to anywhere in the query.
+type logSearch struct {
+	condition string
+	// Relevance of a log, empty when the match mode can't be ranked
+	rank string
+	// The message and traceback highlights. They're slow enough that they should only be selected for the logs of a page.
+	headlines string
+	args      []any
+	// Postgres settings for the query's transaction, as name and value pairs
+	settings [][2]string
+}
+
+func newLogSearch(q string, match string, similarity float64) (logSearch, error) {
+	switch match {
+	case MATCH_FULLTEXT, "":
+		tsquery, err := search.ToTsquery(q)
+		if err != nil {
+			return logSearch{}, errors.New(error_msgs.GetSearchErrorMessage(err.Error()))
+		}
+		query := fmt.Sprintf("to_tsquery('%s', $2)", SEARCH_CONFIG)
+		return logSearch{
+			condition: "search_vector @@ " + query,
+			// Message matches weigh more than traceback matches, see the log.search_vector column
+			rank: fmt.Sprintf("ts_rank(search_vector, %s)", query),
+			headlines: fmt.Sprintf(`ts_headline('%[1]s', coalesce(message, ''), %[2]s, '%[3]s'),
+CASE WHEN to_tsvector('%[1]s', coalesce(traceback, '')) @@ %[2]s THEN ts_headline('%[1]s', traceback, %[2]s, '%[3]s') END`, SEARCH_CONFIG, query, SEARCH_HEADLINE_OPTIONS),
+			args: []any{tsquery},
+		}, nil
+	case MATCH_FUZZY:
+		if similarity == 0 {
+			similarity = DEFAULT_SIMILARITY_THRESHOLD
+		}
+		if similarity < 0 || similarity > 1 {
+			return logSearch{}, errors.New(error_msgs.GetInvalidMessage("similarity"))
+		}
+		q = strings.TrimSpace(q)
+		if q == "" {
+			return logSearch{}, errors.New(error_msgs.GetSearchErrorMessage("search is empty"))
+		}
+		// <% compares against the most similar run of words in the message, so a short search can match a long message
+		return logSearch{
+			condition: "$2 <% message",
+			rank:      "word_similarity($2, message)",
+			args:      []any{q},
+			settings: [][2]string{
+				{"pg_trgm.word_similarity_threshold", fmt.Sprint(similarity)},
+				{"statement_timeout", SEARCH_STATEMENT_TIMEOUT},
+			},
+		}, nil
+	case MATCH_SUBSTRING:
+		if len([]rune(q)) < search.MIN_SUBSTRING_LENGTH {
+			return logSearch{}, errors.New(error_msgs.GetSearchErrorMessage(fmt.Sprintf("substring needs at least %d characters", search.MIN_SUBSTRING_LENGTH)))
+		}
+		return logSearch{
+			condition: "message ILIKE $2",
+			headlines: `regexp_replace(coalesce(message, ''), $3, '<mark>\&</mark>', 'gi'), NULL::text`,
+			args:      []any{"%" + escapeLike(q) + "%", regexp.QuoteMeta(q)},
+		}, nil
+	case MATCH_REGEX:
+		err := search.CheckRegex(q)
+		if err != nil {
+			return logSearch{}, errors.New(error_msgs.GetSearchErrorMessage(err.Error()))
+		}
+		return logSearch{
+			condition: "message ~ $2",
+			headlines: `regexp_replace(coalesce(message, ''), $2, '<mark>\&</mark>', 'g'), NULL::text`,
+			args:      []any{q},
+			settings:  [][2]string{{"statement_timeout", SEARCH_STATEMENT_TIMEOUT}},
+		}, nil
+	}
+	return logSearch{}, errors.New(error_msgs.GetInvalidMessage("match"))
+}
+
+// Runs a query of logs, in a transaction applying the search's settings when it has any.
+// done closes the rows and must be called once they've been read.
+func (db Db) querySearch(textSearch logSearch, query string, args queryArgs) (*sql.Rows, func(), error) {
+	if len(textSearch.settings) == 0 {
+		rows, err := db.Db.Query(query, args.values...)
+		if err != nil {
+			return nil, nil, db.searchError(err)
+		}
+		return rows, func() { rows.Close() }, nil
+	}
+	tx, err := db.Db.Begin()
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	for _, setting := range textSearch.settings {
+		_, err = tx.Exec("SELECT set_config($1, $2, true)", setting[0], setting[1])
+		if err != nil {
+			tx.Rollback()
+			db.Logger.Println(err)
+			return nil, nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+	}
+	rows, err := tx.Query(query, args.values...)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, db.searchError(err)
+	}
+	// Nothing is written, rolling back just discards the settings
+	return rows, func() {
+		rows.Close()
+		tx.Rollback()
+	}, nil
+}
+
+// Logs a failed query of logs, describing it when it's down to the search
+func (db Db) searchError(err error) error {
+	db.Logger.Println(err)
+	if msg, ok := searchQueryError(err); ok {
+		return errors.New(msg)
+	}
+	return errors.New(error_msgs.DATABASE_ERROR)
+}
+
+// Escapes the wildcards of a LIKE pattern so the text only matches itself
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// Describes failures of a search query that are down to the search rather than the database: regexes postgres
+// can't compile despite passing search.CheckRegex, and searches cancelled by SEARCH_STATEMENT_TIMEOUT
+func searchQueryError(err error) (string, bool) {
+	if strings.Contains(err.Error(), "invalid regular expression") {
+		return error_msgs.GetSearchErrorMessage("postgres couldn't compile the regex"), true
+	}
+	if strings.Contains(err.Error(), "statement timeout") {
+		return error_msgs.GetSearchErrorMessage("search took too long, narrow it with more filters or a longer literal"), true
+	}
+	return "", false
+}
@@ -0,0 +1,305 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/lib/pq"
+)
+
+// Range counted when a stats filter doesn't set From
+const DEFAULT_STATS_RANGE = 7 * 24 * time.Hour
+
+const DEFAULT_STATS_BUCKET = 24 * time.Hour
+const MIN_STATS_BUCKET = time.Minute
+
+// Most buckets a histogram can have, a narrower range or larger bucket is needed beyond it
+const MAX_STATS_BUCKETS = 1000
+
+// Counts of the logs in a bucket, by level id
+type LogBucket struct {
+	Start  time.Time   `json:"start"`
+	Total  int         `json:"total"`
+	Counts map[int]int `json:"counts"`
+}
+
+// A histogram of log volume. Every bucket in the range is returned, including empty ones.
+type LogStats struct {
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	BucketSeconds int       `json:"bucket_seconds"`
+	Total         int       `json:"total"`
+	// The levels counted in any bucket
+	Levels  []LogLevel  `json:"levels"`
+	Buckets []LogBucket `json:"buckets"`
+}
+
+// Counts of a project's logs by level id
+type ProjectLogSummary struct {
+	ProjectId   string      `json:"project_id"`
+	ProjectName string      `json:"project_name"`
+	Total       int         `json:"total"`
+	Counts      map[int]int `json:"counts"`
+	LastLogAt   time.Time   `json:"last_log_at"`
+}
+
+type ProjectLogStats struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// The levels counted in any project
+	Levels   []LogLevel          `json:"levels"`
+	Projects []ProjectLogSummary `json:"projects"`
+}
+
+// Fills in the range of a stats filter, the DEFAULT_STATS_RANGE up to now when it isn't set
+func statsRange(filter *LogFilter) (time.Time, time.Time, error) {
+	to := time.Now()
+	if filter.To != nil {
+		to = *filter.To
+	}
+	from := to.Add(-DEFAULT_STATS_RANGE)
+	if filter.From != nil {
+		from = *filter.From
+	}
+	if !from.Before(to) {
+		return from, to, errors.New(error_msgs.GetInvalidMessage("from"))
+	}
+	filter.From = &from
+	filter.To = &to
+	return from, to, nil
+}
+
+// Counts the logs matching the filter per bucket and level, between its From and To.
+// Buckets start at From and are bucket long, which defaults to DEFAULT_STATS_BUCKET when 0.
+func (db Db) GetLogStats(userId string, filter LogFilter, bucket time.Duration) (LogStats, error) {
+	if bucket == 0 {
+		bucket = DEFAULT_STATS_BUCKET
+	}
+	if bucket < MIN_STATS_BUCKET || bucket%time.Second != 0 {
+		return LogStats{}, errors.New(error_msgs.GetInvalidMessage("bucket"))
+	}
+	from, to, err := statsRange(&filter)
+	if err != nil {
+		return LogStats{}, err
+	}
+	// The last bucket starts at or before To, so a log at exactly To is counted
+	count := int(to.Sub(from)/bucket) + 1
+	if count > MAX_STATS_BUCKETS {
+		return LogStats{}, errors.New(error_msgs.GetInvalidMessage("bucket"))
+	}
+	textSearch, args, conditions, err := filter.conditions(userId)
+	if err != nil {
+		return LogStats{}, err
+	}
+	query := fmt.Sprintf(`SELECT date_bin(%s::interval, created_at, %s::timestamptz) AS bucket_start, level_id, count(*)
+FROM log
+WHERE %s
+GROUP BY bucket_start, level_id`, args.add(fmt.Sprintf("%d seconds", int(bucket.Seconds()))), args.add(from), allOf(conditions))
+	rows, done, err := db.querySearch(textSearch, query, args)
+	if err != nil {
+		return LogStats{}, err
+	}
+	defer done()
+	stats := LogStats{From: from, To: to, BucketSeconds: int(bucket.Seconds()), Buckets: make([]LogBucket, count)}
+	for i := range stats.Buckets {
+		stats.Buckets[i] = LogBucket{Start: from.Add(time.Duration(i) * bucket), Counts: map[int]int{}}
+	}
+	levelIds := map[int]bool{}
+	for rows.Next() {
+		var start time.Time
+		var levelId, n int
+		err = rows.Scan(&start, &levelId, &n)
+		if err != nil {
+			db.Logger.Println(err)
+			return LogStats{}, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		i := int(start.Sub(from) / bucket)
+		if i < 0 || i >= count {
+			continue
+		}
+		stats.Buckets[i].Counts[levelId] += n
+		stats.Buckets[i].Total += n
+		stats.Total += n
+		levelIds[levelId] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return LogStats{}, db.searchError(err)
+	}
+	stats.Levels, err = db.getLevelsById(levelIds)
+	if err != nil {
+		return LogStats{}, err
+	}
+	return stats, nil
+}
+
+// Orders of GetProjectLogStats. Projects can also be ordered by the count of a level with PROJECT_SORT_LEVEL followed by its name or id, eg level:ERROR.
+const (
+	PROJECT_SORT_TOTAL = "total"
+	PROJECT_SORT_NAME  = "name"
+	PROJECT_SORT_LEVEL = "level:"
+)
+
+// Directions of GetProjectLogStats. Counts are sorted descending and names ascending by default.
+const (
+	SORT_ASC  = "asc"
+	SORT_DESC = "desc"
+)
+
+// Counts the logs matching the filter per project and level, between its From and To. Projects without a matching log are left out.
+// Projects are ordered by one of the PROJECT_SORT_ orders, PROJECT_SORT_TOTAL when empty, in the order direction.
+func (db Db) GetProjectLogStats(userId string, filter LogFilter, sortBy string, order string) (ProjectLogStats, error) {
+	if sortBy == "" {
+		sortBy = PROJECT_SORT_TOTAL
+	}
+	sortLevel, byLevel := strings.CutPrefix(sortBy, PROJECT_SORT_LEVEL)
+	if sortBy != PROJECT_SORT_TOTAL && sortBy != PROJECT_SORT_NAME && (!byLevel || strings.TrimSpace(sortLevel) == "") {
+		return ProjectLogStats{}, errors.New(error_msgs.GetInvalidMessage("sort"))
+	}
+	if order == "" {
+		order = SORT_DESC
+		if sortBy == PROJECT_SORT_NAME {
+			order = SORT_ASC
+		}
+	}
+	if order != SORT_ASC && order != SORT_DESC {
+		return ProjectLogStats{}, errors.New(error_msgs.GetInvalidMessage("order"))
+	}
+	from, to, err := statsRange(&filter)
+	if err != nil {
+		return ProjectLogStats{}, err
+	}
+	textSearch, args, conditions, err := filter.conditions(userId)
+	if err != nil {
+		return ProjectLogStats{}, err
+	}
+	// Counted before joining project, whose columns would clash with the log's in the conditions
+	query := `SELECT counts.project_id, project.name, counts.level_id, counts.count, counts.last_log_at
+FROM (
+	SELECT project_id, level_id, count(*) AS count, max(created_at) AS last_log_at
+	FROM log
+	WHERE ` + allOf(conditions) + `
+	GROUP BY project_id, level_id
+) AS counts
+INNER JOIN project ON project.id = counts.project_id`
+	rows, done, err := db.querySearch(textSearch, query, args)
+	if err != nil {
+		return ProjectLogStats{}, err
+	}
+	defer done()
+	summaries := map[string]*ProjectLogSummary{}
+	levelIds := map[int]bool{}
+	for rows.Next() {
+		var projectId, projectName string
+		var levelId, n int
+		var lastLogAt time.Time
+		err = rows.Scan(&projectId, &projectName, &levelId, &n, &lastLogAt)
+		if err != nil {
+			db.Logger.Println(err)
+			return ProjectLogStats{}, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		summary, ok := summaries[projectId]
+		if !ok {
+			summary = &ProjectLogSummary{ProjectId: projectId, ProjectName: projectName, Counts: map[int]int{}}
+			summaries[projectId] = summary
+		}
+		summary.Counts[levelId] += n
+		summary.Total += n
+		if lastLogAt.After(summary.LastLogAt) {
+			summary.LastLogAt = lastLogAt
+		}
+		levelIds[levelId] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return ProjectLogStats{}, db.searchError(err)
+	}
+	stats := ProjectLogStats{From: from, To: to, Projects: []ProjectLogSummary{}}
+	stats.Levels, err = db.getLevelsById(levelIds)
+	if err != nil {
+		return ProjectLogStats{}, err
+	}
+	sortCount := func(summary ProjectLogSummary) int {
+		return summary.Total
+	}
+	if byLevel {
+		matches := levelMatcher(sortLevel, stats.Levels)
+		sortCount = func(summary ProjectLogSummary) int {
+			n := 0
+			for levelId, count := range summary.Counts {
+				if matches[levelId] {
+					n += count
+				}
+			}
+			return n
+		}
+	}
+	for _, summary := range summaries {
+		stats.Projects = append(stats.Projects, *summary)
+	}
+	sort.Slice(stats.Projects, func(i, j int) bool {
+		a, b := stats.Projects[i], stats.Projects[j]
+		if sortBy != PROJECT_SORT_NAME && sortCount(a) != sortCount(b) {
+			return sortCount(a) > sortCount(b) == (order == SORT_DESC)
+		}
+		if a.ProjectName != b.ProjectName {
+			return a.ProjectName < b.ProjectName == (order == SORT_ASC || sortBy != PROJECT_SORT_NAME)
+		}
+		return a.ProjectId < b.ProjectId
+	})
+	return stats, nil
+}
+
+// The ids among levels that a level name or id refers to. Project levels with the same name in different projects all match.
+func levelMatcher(level string, levels []LogLevel) map[int]bool {
+	matches := map[int]bool{}
+	if id, err := strconv.Atoi(level); err == nil {
+		matches[id] = true
+		return matches
+	}
+	names := levelNames(level)
+	for _, l := range levels {
+		for _, name := range names {
+			if strings.ToUpper(l.Name) == name {
+				matches[l.Id] = true
+			}
+		}
+	}
+	return matches
+}
+
+// Reads the levels with the given ids, least severe first
+func (db Db) getLevelsById(ids map[int]bool) ([]LogLevel, error) {
+	levels := []LogLevel{}
+	if len(ids) == 0 {
+		return levels, nil
+	}
+	idList := make([]int, 0, len(ids))
+	for id := range ids {
+		idList = append(idList, id)
+	}
+	rows, err := db.Db.Query(`SELECT id, project_id, value, severity
+FROM log_level
+WHERE id = ANY($1::int[])
+ORDER BY severity, id`, pq.Array(idList))
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var level LogLevel
+		err = rows.Scan(&level.Id, &level.ProjectId, &level.Name, &level.Severity)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
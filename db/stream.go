@@ -0,0 +1,75 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/lib/pq"
+)
+
+// Most logs read per query when replaying logs to a tail, a longer replay is read in pages
+const MAX_STREAM_REPLAY = 1000
+
+// Returns up to MAX_STREAM_REPLAY logs matching the filter that may not have been read before the cursor, a StreamCursor,
+// in (commit_seq, id) order. When the page is full the cursor to read the next one from is returned too.
+func (db Db) GetLogsSince(userId string, filter LogFilter, cursor string) ([]Log, string, error) {
+	position, err := decodeStreamCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	logs, err := db.streamLogs(userId, filter, func(args *queryArgs) string {
+		seq := args.add(position.CommitSeq)
+		return fmt.Sprintf("commit_seq >= %s AND (commit_seq < %s OR (commit_seq, id) > (%s, %s))", args.add(position.Horizon), seq, seq, args.add(position.Id))
+	})
+	if err != nil || len(logs) < MAX_STREAM_REPLAY {
+		return logs, "", err
+	}
+	// The next page follows on from this one, logs before its last have all just been read
+	last := logs[len(logs)-1]
+	return logs, encodeStreamCursor(streamCursor{CommitSeq: *last.CommitSeq, Horizon: *last.CommitSeq, Id: last.Id}), nil
+}
+
+// Returns the logs among ids that match the filter and are in projects the user can read, in the order they were committed
+func (db Db) GetStreamLogs(userId string, filter LogFilter, ids []string) ([]Log, error) {
+	if len(ids) == 0 {
+		return []Log{}, nil
+	}
+	return db.streamLogs(userId, filter, func(args *queryArgs) string {
+		return "id = ANY(" + args.add(pq.Array(ids)) + "::uuid[])"
+	})
+}
+
+func (db Db) streamLogs(userId string, filter LogFilter, condition func(args *queryArgs) string) ([]Log, error) {
+	textSearch, args, conditions, err := filter.conditions(userId)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append(conditions, condition(&args))
+	// Read first so it's no later than the snapshot the logs are read with
+	horizon, err := db.streamHorizon()
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM log WHERE %s ORDER BY commit_seq, id LIMIT %s", LOG_SELECT_COLUMNS, allOf(conditions), args.add(MAX_STREAM_REPLAY))
+	rows, done, err := db.querySearch(textSearch, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	logs := []Log{}
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		log.horizon = horizon
+		logs = append(logs, log)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, db.searchError(err)
+	}
+	return logs, nil
+}
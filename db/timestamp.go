@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// What to do with a client timestamp outside the accepted skew, set with LOG_TIMESTAMP_POLICY
+const (
+	// Moves the timestamp to the nearest accepted time and flags the log
+	TIMESTAMP_CLAMP = "clamp"
+	// Rejects the log
+	TIMESTAMP_REJECT = "reject"
+	// Keeps the timestamp as sent and flags the log
+	TIMESTAMP_FLAG = "flag"
+)
+
+const DEFAULT_TIMESTAMP_POLICY = TIMESTAMP_CLAMP
+
+// How far a client timestamp may be ahead of or behind the time the log was received,
+// overridden with LOG_MAX_FUTURE_SKEW and LOG_MAX_PAST_SKEW as go durations, eg "5m" or "168h"
+const DEFAULT_MAX_FUTURE_SKEW = 5 * time.Minute
+const DEFAULT_MAX_PAST_SKEW = 7 * 24 * time.Hour
+
+type TimestampPolicy struct {
+	Policy        string
+	MaxFutureSkew time.Duration
+	MaxPastSkew   time.Duration
+}
+
+func getTimestampPolicy() (TimestampPolicy, error) {
+	policy := TimestampPolicy{
+		Policy:        DEFAULT_TIMESTAMP_POLICY,
+		MaxFutureSkew: DEFAULT_MAX_FUTURE_SKEW,
+		MaxPastSkew:   DEFAULT_MAX_PAST_SKEW,
+	}
+	if value := os.Getenv("LOG_TIMESTAMP_POLICY"); value != "" {
+		if value != TIMESTAMP_CLAMP && value != TIMESTAMP_REJECT && value != TIMESTAMP_FLAG {
+			return policy, fmt.Errorf("LOG_TIMESTAMP_POLICY must be %s, %s or %s", TIMESTAMP_CLAMP, TIMESTAMP_REJECT, TIMESTAMP_FLAG)
+		}
+		policy.Policy = value
+	}
+	var err error
+	if value := os.Getenv("LOG_MAX_FUTURE_SKEW"); value != "" {
+		policy.MaxFutureSkew, err = time.ParseDuration(value)
+		if err != nil {
+			return policy, fmt.Errorf("LOG_MAX_FUTURE_SKEW: %w", err)
+		}
+	}
+	if value := os.Getenv("LOG_MAX_PAST_SKEW"); value != "" {
+		policy.MaxPastSkew, err = time.ParseDuration(value)
+		if err != nil {
+			return policy, fmt.Errorf("LOG_MAX_PAST_SKEW: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// Returns the event time to store for a client timestamp and whether it was outside the accepted skew.
+// ok is false when the policy rejects the timestamp.
+func (p TimestampPolicy) apply(timestamp time.Time, receivedAt time.Time) (eventTime time.Time, skewed bool, ok bool) {
+	earliest := receivedAt.Add(-p.MaxPastSkew)
+	latest := receivedAt.Add(p.MaxFutureSkew)
+	if !timestamp.Before(earliest) && !timestamp.After(latest) {
+		return timestamp, false, true
+	}
+	switch p.Policy {
+	case TIMESTAMP_REJECT:
+		return timestamp, true, false
+	case TIMESTAMP_FLAG:
+		return timestamp, true, true
+	}
+	if timestamp.Before(earliest) {
+		return earliest, true, true
+	}
+	return latest, true, true
+}
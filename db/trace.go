@@ -0,0 +1,118 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Lengths of W3C trace context ids once hex encoded
+const TRACE_ID_LENGTH = 32
+const SPAN_ID_LENGTH = 16
+
+func isHexId(id string, length int) bool {
+	if len(id) != length || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether id is a lowercase hex trace id that isn't all zeros, as W3C trace context requires
+func ValidTraceId(id string) bool {
+	return isHexId(id, TRACE_ID_LENGTH)
+}
+
+func ValidSpanId(id string) bool {
+	return isHexId(id, SPAN_ID_LENGTH)
+}
+
+// Parses a W3C traceparent header, eg "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func ParseTraceparent(header string) (traceId string, spanId string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(strings.ToLower(header)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", "", false
+	}
+	// Version 00 has exactly four fields, later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	if !ValidTraceId(parts[1]) || !ValidSpanId(parts[2]) {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Fills in the trace and span from a traceparent header when the entry doesn't carry its own trace.
+// The header's parent id is the caller's active span, which is the span the log was written in.
+func (entry *LogEntry) ApplyTraceparent(header string) {
+	if entry.TraceId != nil || header == "" {
+		return
+	}
+	traceId, spanId, ok := ParseTraceparent(header)
+	if !ok {
+		return
+	}
+	entry.TraceId = &traceId
+	if entry.SpanId == nil {
+		entry.SpanId = &spanId
+	}
+}
+
+func validateTraceIds(entry *LogEntry) error {
+	if entry.TraceId != nil {
+		id := strings.ToLower(*entry.TraceId)
+		if !ValidTraceId(id) {
+			return errors.New(error_msgs.GetInvalidMessage("trace_id"))
+		}
+		entry.TraceId = &id
+	}
+	if entry.SpanId != nil {
+		id := strings.ToLower(*entry.SpanId)
+		if !ValidSpanId(id) {
+			return errors.New(error_msgs.GetInvalidMessage("span_id"))
+		}
+		entry.SpanId = &id
+	}
+	if entry.ParentSpanId != nil {
+		id := strings.ToLower(*entry.ParentSpanId)
+		if !ValidSpanId(id) {
+			return errors.New(error_msgs.GetInvalidMessage("parent_span_id"))
+		}
+		entry.ParentSpanId = &id
+	}
+	return nil
+}
+
+// Returns every log of a trace across the projects the user is permitted to see, oldest first
+func (db Db) GetTraceLogs(userId string, traceId string) ([]Log, error) {
+	traceId = strings.ToLower(traceId)
+	if !ValidTraceId(traceId) {
+		return nil, errors.New(error_msgs.GetInvalidMessage("trace_id"))
+	}
+	rows, err := db.Db.Query(`SELECT `+LOG_SELECT_COLUMNS+`
+FROM log
+WHERE trace_id = $1
+AND project_id IN (`+readableProjects("$2")+`)
+ORDER BY created_at, id`, traceId, userId)
+	if err != nil {
+		db.Logger.Println(err)
+		return nil, errors.New(error_msgs.DATABASE_ERROR)
+	}
+	defer rows.Close()
+	logs := []Log{}
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			db.Logger.Println(err)
+			return nil, errors.New(error_msgs.DATABASE_ERROR)
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/jesses-code-adventures/every_log/error_msgs"
 )
@@ -82,3 +84,42 @@ func (db Db) GetCurrentUser() ([]byte, error) {
 	}
 	return parsed, nil
 }
+
+// Generates a random (version 4) uuid so that ids can be assigned before rows are inserted
+func NewUuid() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// Returns the placeholders "$from, $from+1, ..." for n query parameters
+func placeholders(from int, n int) string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = fmt.Sprintf("$%d", from+i)
+	}
+	return strings.Join(values, ", ")
+}
+
+// Whether s is a uuid in its canonical hyphenated form, so malformed path ids can be rejected before they reach postgres
+func isUuid(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if c != '-' {
+				return false
+			}
+			continue
+		}
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
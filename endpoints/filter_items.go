@@ -0,0 +1,66 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+type FilterItemsHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (f FilterItemsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		f.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (f FilterItemsHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := f.get(r)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns the projects and orgs the user can access, with facet counts of the logs matching the filters of GET /log, eg "?level=ERROR&project_id=..."
+func (f FilterItemsHandler) get(r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	filter, err := logFilter(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	items, err := f.Db.GetFilterItems(userId, filter)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := json.Marshal(items)
+	if err != nil {
+		f.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return resp, nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/ingest"
 )
 
 type ServerHandler struct {
@@ -17,11 +18,12 @@ type ServerHandler struct {
 	project      ProjectHandler
 	dbUser       DbUserHandler
 	log          LogHandler
+	process      ProcessHandler
 	org          OrgHandler
 	Logger       *log.Logger
 }
 
-func NewServerHandler(db *db.Db, logger *log.Logger) ServerHandler {
+func NewServerHandler(db *db.Db, queue *ingest.Queue, logger *log.Logger) ServerHandler {
 	handler := ServerHandler{
 		db:           db,
 		user:         UserHandler{Db: db, Logger: logger},
@@ -31,8 +33,10 @@ func NewServerHandler(db *db.Db, logger *log.Logger) ServerHandler {
 		authorize:    AuthorizationHandler{Db: db, Logger: logger},
 		project:      ProjectHandler{Db: db, Logger: logger},
 		dbUser:       DbUserHandler{Db: db, Logger: logger},
-		log:          LogHandler{Db: db, Logger: logger},
+		log:          LogHandler{Db: db, Queue: queue, Logger: logger},
+		process:      ProcessHandler{Db: db, Logger: logger},
 		org:          OrgHandler{Db: db, Logger: logger},
+		Logger:       logger,
 	}
 	return handler
 }
@@ -47,6 +51,17 @@ func (s *ServerHandler) HandleAuthMiddleware(w http.ResponseWriter, r *http.Requ
 	handler.ServeHTTP(w, r)
 }
 
+// Wraps a handler registered directly on the mux with the same validation and auth as the routes below
+func (s *ServerHandler) Authorized(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		basicErr := BasicValidateRequest(w, r)
+		if basicErr != nil {
+			return
+		}
+		s.HandleAuthMiddleware(w, r, handler.ServeHTTP)
+	})
+}
+
 func (s *ServerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	basicErr := BasicValidateRequest(w, r)
 	if basicErr != nil {
@@ -74,6 +89,8 @@ You can create a user by sending a POST request to /user, get a list of tables b
 		s.HandleAuthMiddleware(w, r, s.project.ServeHTTP)
 	case "/log":
 		s.HandleAuthMiddleware(w, r, s.log.ServeHTTP)
+	case "/process":
+		s.HandleAuthMiddleware(w, r, s.process.ServeHTTP)
 	case "/org":
 		s.HandleAuthMiddleware(w, r, s.org.ServeHTTP)
 	}
@@ -0,0 +1,158 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+type ProjectIssuesHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (p ProjectIssuesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		p.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (p ProjectIssuesHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	projectId := r.PathValue("project_id")
+	if projectId == "" {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.GetRequiredMessage("project_id")), http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		issues, err := p.get(r, projectId)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(issues)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Lists the project's issues, optionally only those with the status given by the status query parameter
+func (p ProjectIssuesHandler) get(r *http.Request, projectId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	resp, err := p.Db.GetIssues(userId, projectId, r.URL.Query().Get("status"))
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		p.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+type IssueHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (i IssueHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		i.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (i IssueHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	issueId := r.PathValue("issue_id")
+	if issueId == "" {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.GetRequiredMessage("issue_id")), http.StatusBadRequest)
+		return
+	}
+	var resp []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = i.get(r, issueId)
+	case http.MethodPatch:
+		resp, err = i.update(r, issueId)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		status := error_msgs.GetErrorHttpStatus(err)
+		http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+func (i IssueHandler) get(r *http.Request, issueId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	resp, err := i.Db.GetIssue(userId, issueId)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		i.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+// Resolves, ignores or reopens the issue, depending on the status in the body
+func (i IssueHandler) update(r *http.Request, issueId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	body := r.Body
+	defer body.Close()
+	var parsedBody struct {
+		Status string `json:"status"`
+	}
+	err := json.NewDecoder(body).Decode(&parsedBody)
+	if err != nil {
+		i.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	if parsedBody.Status == "" {
+		return nil, errors.New(error_msgs.GetRequiredMessage("status"))
+	}
+	resp, err := i.Db.SetIssueStatus(userId, issueId, parsedBody.Status)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		i.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
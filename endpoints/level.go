@@ -0,0 +1,100 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+type ProjectLevelHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (p ProjectLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		p.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (p ProjectLevelHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	projectId := r.PathValue("project_id")
+	if projectId == "" {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.GetRequiredMessage("project_id")), http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		id, err := p.create(r, projectId)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(`{"id": %d}`, id)))
+	case http.MethodGet:
+		levels, err := p.get(r, projectId)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(levels)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Defines a level such as TRACE or AUDIT for the project
+func (p ProjectLevelHandler) create(r *http.Request, projectId string) (int, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return 0, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	body := r.Body
+	defer body.Close()
+	var parsedBody struct {
+		Name     string `json:"name"`
+		Severity *int   `json:"severity"`
+	}
+	err := json.NewDecoder(body).Decode(&parsedBody)
+	if err != nil {
+		p.Logger.Println(err)
+		return 0, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	if parsedBody.Severity == nil {
+		return 0, errors.New(error_msgs.GetRequiredMessage("severity"))
+	}
+	return p.Db.CreateLevel(userId, projectId, parsedBody.Name, *parsedBody.Severity)
+}
+
+// Lists the levels logs in the project can use, built in ones included
+func (p ProjectLevelHandler) get(r *http.Request, projectId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	resp, err := p.Db.GetLevels(userId, projectId)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		p.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
@@ -6,14 +6,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"time"
 
 	"github.com/jesses-code-adventures/every_log/db"
 	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/ingest"
 )
 
 type LogHandler struct {
 	Db     *db.Db
+	Queue  *ingest.Queue
 	Logger *log.Logger
 }
 
@@ -32,13 +33,31 @@ func (p LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (p LogHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
+		if isNdjson(r) {
+			summary, err := p.createNdjson(r)
+			if err != nil && summary == nil {
+				status := error_msgs.GetErrorHttpStatus(err)
+				http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err != nil {
+				w.WriteHeader(error_msgs.GetErrorHttpStatus(err))
+			}
+			w.Write(summary)
+			return
+		}
 		id, err := p.create(r)
 		if err != nil {
+			if err.Error() == error_msgs.QUEUE_FULL {
+				w.Header().Set("Retry-After", fmt.Sprint(ingest.RETRY_AFTER_SECONDS))
+			}
 			status := error_msgs.GetErrorHttpStatus(err)
 			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
 		w.Write([]byte(fmt.Sprintf(`{"id": %s}`, id)))
 	case http.MethodGet:
 		logs, err := p.get(r)
@@ -54,6 +73,8 @@ func (p LogHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Validates the log and queues it for writing, returning its id before it reaches the database.
+// An Idempotency-Key header is used as the entry's id when the body doesn't set one, as is a traceparent header for its trace.
 func (p LogHandler) create(r *http.Request) ([]byte, error) {
 	userId := r.Header.Get("user_id")
 	if userId == "" {
@@ -66,23 +87,37 @@ func (p LogHandler) create(r *http.Request) ([]byte, error) {
 	arr := make([]byte, 0)
 	body := r.Body
 	defer body.Close()
-	var parsedBody struct {
-		ProjectId string  `json:"project_id"`
-		LevelId   int     `json:"level_id"`
-		ProcessId *string `json:"process_id"`
-		Message   string  `json:"message"`
-		Traceback *string `json:"traceback"`
-	}
+	var parsedBody db.LogEntry
 	err := json.NewDecoder(body).Decode(&parsedBody)
 	if err != nil {
 		p.Logger.Println(err)
 		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
 	}
-	resp, err := p.Db.CreateLog(userId, parsedBody.ProjectId, parsedBody.LevelId, parsedBody.ProcessId, parsedBody.Message, parsedBody.Traceback, apiKey)
+	if key := r.Header.Get("Idempotency-Key"); key != "" && parsedBody.Id == nil {
+		parsedBody.Id = &key
+	}
+	parsedBody.ApplyTraceparent(r.Header.Get("traceparent"))
+	projectId, err := p.Db.GetApiKeyProjectId(userId, apiKey)
 	if err != nil {
 		return nil, err
 	}
-	arr, err = json.Marshal(resp)
+	entries := []db.LogEntry{parsedBody}
+	errs, err := p.Db.PrepareLogs(userId, projectId, entries)
+	if err != nil {
+		return nil, err
+	}
+	if errs[0] != nil {
+		return nil, errs[0]
+	}
+	// A retry of a log that was already accepted gets the same id back
+	if !entries[0].IsDuplicate() {
+		err = p.Queue.Enqueue(entries[0])
+		if err != nil {
+			p.Db.ReleaseLogs(entries)
+			return nil, err
+		}
+	}
+	arr, err = json.Marshal(entries[0].LogId)
 	if err != nil {
 		p.Logger.Println(err)
 		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
@@ -90,37 +125,34 @@ func (p LogHandler) create(r *http.Request) ([]byte, error) {
 	return arr, err
 }
 
+// Returns a page of logs matching the filters in the query string, eg
+// "?project_id=...&level=ERROR&level=CRITICAL&from=2024-05-01T00:00:00Z&q=timeout&limit=50&cursor=...",
+// or with the query language, "?query=level>=WARNING process:worker since:15m".
+// The previous page's next_cursor is passed as cursor to get the next one.
 func (p LogHandler) get(r *http.Request) ([]byte, error) {
 	userId := r.Header.Get("user_id")
 	if userId == "" {
 		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
 	}
-	arr := make([]byte, 0)
-	body := r.Body
-	defer body.Close()
-	var parsedBody struct {
-		ProjectId *string    `json:"project_id"`
-		LevelId   *int       `json:"level_id"`
-		ProcessId *string    `json:"process_id"`
-		OrgId     *string    `json:"org_id"`
-		Message   *string    `json:"message"`
-		Traceback *string    `json:"traceback"`
-		From      *time.Time `json:"from"`
-		To        *time.Time `json:"to"`
+	query := r.URL.Query()
+	filter, err := logFilter(query)
+	if err != nil {
+		return nil, err
 	}
-	err := json.NewDecoder(body).Decode(&parsedBody)
+	filter.Sort = query.Get("sort")
+	filter.Cursor = query.Get("cursor")
+	filter.Limit, err = queryInt(query, "limit")
 	if err != nil {
-		p.Logger.Println(err)
-		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+		return nil, err
 	}
-	resp, err := p.Db.GetLogs(userId, parsedBody.ProjectId, parsedBody.LevelId, parsedBody.ProcessId, parsedBody.OrgId, parsedBody.From, parsedBody.To)
+	resp, err := p.Db.GetLogs(userId, filter)
 	if err != nil {
 		return nil, err
 	}
-	arr, err = json.Marshal(resp)
+	arr, err := json.Marshal(resp)
 	if err != nil {
 		p.Logger.Println(err)
 		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
 	}
-	return arr, err
+	return arr, nil
 }
@@ -0,0 +1,131 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+const MAX_BATCH_SIZE = 1000
+const BATCH_MAX_BODY_BYTES = 16 * 1024 * 1024
+
+type LogBatchHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (p LogBatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		p.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (p LogBatchHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		resp, err := p.create(w, r)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Takes a JSON array of logs and reports the outcome of each entry by its index in the array
+func (p LogBatchHandler) create(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	apiKey := r.Header.Get("api_key")
+	if apiKey == "" {
+		return nil, errors.New(error_msgs.API_KEY_REQUIRED)
+	}
+	body := http.MaxBytesReader(w, r.Body, BATCH_MAX_BODY_BYTES)
+	defer body.Close()
+	entries, err := decodeBatch(body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errors.New(error_msgs.BODY_TOO_LARGE)
+		}
+		if err.Error() == error_msgs.BATCH_TOO_LARGE {
+			return nil, err
+		}
+		p.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	traceparent := r.Header.Get("traceparent")
+	for i := range entries {
+		entries[i].ApplyTraceparent(traceparent)
+	}
+	results, err := p.Db.CreateLogs(userId, entries, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	response := struct {
+		Accepted int            `json:"accepted"`
+		Rejected int            `json:"rejected"`
+		Results  []db.LogResult `json:"results"`
+	}{
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			response.Rejected++
+		} else {
+			response.Accepted++
+		}
+	}
+	arr, err := json.Marshal(response)
+	if err != nil {
+		p.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+// Reads a JSON array of logs one entry at a time, stopping as soon as it holds more than MAX_BATCH_SIZE
+func decodeBatch(body io.Reader) ([]db.LogEntry, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("expected a JSON array of logs")
+	}
+	entries := []db.LogEntry{}
+	for decoder.More() {
+		if len(entries) == MAX_BATCH_SIZE {
+			return nil, errors.New(error_msgs.BATCH_TOO_LARGE)
+		}
+		var entry db.LogEntry
+		err = decoder.Decode(&entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	_, err = decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
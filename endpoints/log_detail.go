@@ -0,0 +1,77 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+type LogDetailHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (l LogDetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		l.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (l LogDetailHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		logId := r.PathValue("log_id")
+		if logId == "" {
+			http.Error(w, error_msgs.JsonifyError(error_msgs.GetRequiredMessage("log_id")), http.StatusBadRequest)
+			return
+		}
+		resp, err := l.get(r, logId)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns the log along with the structured frames parsed from its traceback, the names of its project, process and level,
+// and the logs around it from the same process, eg "?before=20&after=5"
+func (l LogDetailHandler) get(r *http.Request, logId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	query := r.URL.Query()
+	before, err := queryInt(query, "before")
+	if err != nil {
+		return nil, err
+	}
+	after, err := queryInt(query, "after")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.Db.GetLogDetail(userId, logId, before, after)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		l.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
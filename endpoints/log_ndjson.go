@@ -0,0 +1,196 @@
+package endpoints
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+const NDJSON_CONTENT_TYPE = "application/x-ndjson"
+
+// Pending entries are written once this many have been read or the interval has passed, whichever is first
+const NDJSON_FLUSH_SIZE = 500
+const NDJSON_FLUSH_INTERVAL = time.Second
+
+// Longest line accepted, tracebacks can be large
+const NDJSON_MAX_LINE_BYTES = 1024 * 1024
+
+// Caps the failures echoed back so a bad shipper can't make the response unbounded
+const NDJSON_MAX_REPORTED_ERRORS = 100
+
+type ndjsonLine struct {
+	number int
+	entry  db.LogEntry
+	err    error
+	// Set when the body couldn't be read past this line, which ends the stream
+	readErr error
+}
+
+type ndjsonError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type ndjsonSummary struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	// Every line up to and including this one was written or rejected, a stream cut short by an error can be resent from the line after it
+	LastLine int           `json:"last_line"`
+	Errors   []ndjsonError `json:"errors"`
+	// Why the stream was cut short, when it was
+	Error *string `json:"error,omitempty"`
+}
+
+func (s *ndjsonSummary) reject(line int, err string) {
+	s.Rejected++
+	if len(s.Errors) < NDJSON_MAX_REPORTED_ERRORS {
+		s.Errors = append(s.Errors, ndjsonError{Line: line, Error: err})
+	}
+}
+
+func isNdjson(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), NDJSON_CONTENT_TYPE)
+}
+
+// Reads one JSON log per line while the body is still uploading, writing them in bounded batches.
+// The api key is checked once for the whole stream. When a write or reading the body fails part way through,
+// the summary of what was written before it is returned along with the error.
+func (p LogHandler) createNdjson(r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	apiKey := r.Header.Get("api_key")
+	if apiKey == "" {
+		return nil, errors.New(error_msgs.API_KEY_REQUIRED)
+	}
+	body := r.Body
+	defer body.Close()
+	projectId, err := p.Db.GetApiKeyProjectId(userId, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	traceparent := r.Header.Get("traceparent")
+	lines := make(chan ndjsonLine)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), NDJSON_MAX_LINE_BYTES)
+		number := 0
+		for scanner.Scan() {
+			number++
+			raw := scanner.Bytes()
+			if len(bytes.TrimSpace(raw)) == 0 {
+				continue
+			}
+			line := ndjsonLine{number: number}
+			line.err = json.Unmarshal(raw, &line.entry)
+			line.entry.ApplyTraceparent(traceparent)
+			select {
+			case lines <- line:
+			case <-done:
+				return
+			}
+		}
+		err := scanner.Err()
+		if err != nil {
+			select {
+			case lines <- ndjsonLine{number: number + 1, readErr: err}:
+			case <-done:
+			}
+		}
+	}()
+	summary := ndjsonSummary{Errors: []ndjsonError{}}
+	lastLine := 0
+	pending := make([]db.LogEntry, 0, NDJSON_FLUSH_SIZE)
+	pendingLines := make([]int, 0, NDJSON_FLUSH_SIZE)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		results, err := p.Db.WriteLogs(userId, projectId, pending)
+		if err != nil {
+			summary.LastLine = pendingLines[0] - 1
+			return err
+		}
+		for i, result := range results {
+			if result.Error != nil {
+				summary.reject(pendingLines[i], *result.Error)
+			} else {
+				summary.Accepted++
+			}
+		}
+		pending = pending[:0]
+		pendingLines = pendingLines[:0]
+		return nil
+	}
+	// Marshals the summary, with the error that cut the stream short if there was one
+	finish := func(cause error) ([]byte, error) {
+		if cause != nil {
+			message := cause.Error()
+			summary.Error = &message
+		}
+		arr, err := json.Marshal(summary)
+		if err != nil {
+			p.Logger.Println(err)
+			return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+		}
+		return arr, cause
+	}
+	ticker := time.NewTicker(NDJSON_FLUSH_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				err = flush()
+				if err != nil {
+					return finish(err)
+				}
+				summary.LastLine = lastLine
+				return finish(nil)
+			}
+			if line.readErr != nil {
+				// Nothing after an unreadable line can be trusted, so what was read before it is written and the rest is resent
+				p.Logger.Println(line.readErr)
+				err = flush()
+				if err != nil {
+					return finish(err)
+				}
+				summary.LastLine = lastLine
+				if errors.Is(line.readErr, bufio.ErrTooLong) {
+					return finish(errors.New(error_msgs.LINE_TOO_LONG))
+				}
+				return finish(errors.New(error_msgs.BODY_READ_ERROR))
+			}
+			lastLine = line.number
+			if line.err != nil {
+				p.Logger.Println(line.err)
+				summary.reject(line.number, error_msgs.JSON_PARSING_ERROR)
+				continue
+			}
+			pending = append(pending, line.entry)
+			pendingLines = append(pendingLines, line.number)
+			if len(pending) >= NDJSON_FLUSH_SIZE {
+				err = flush()
+				if err != nil {
+					return finish(err)
+				}
+			}
+		case <-ticker.C:
+			err = flush()
+			if err != nil {
+				return finish(err)
+			}
+		}
+	}
+}
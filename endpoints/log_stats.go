@@ -0,0 +1,133 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/logquery"
+)
+
+type LogStatsHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (l LogStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		l.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (l LogStatsHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := l.get(r)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns a histogram of the logs matching the filters of GET /log, eg "?from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z&bucket=6h&level=ERROR"
+func (l LogStatsHandler) get(r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	query := r.URL.Query()
+	filter, err := logFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	var bucket time.Duration
+	if value := query.Get("bucket"); value != "" {
+		var ok bool
+		bucket, ok = logquery.ParseDuration(value)
+		if !ok {
+			return nil, errors.New(error_msgs.GetInvalidMessage("bucket"))
+		}
+	}
+	resp, err := l.Db.GetLogStats(userId, filter, bucket)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		l.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+type ProjectLogStatsHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (p ProjectLogStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		p.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (p ProjectLogStatsHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := p.get(r)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Returns each project's log counts by level for the filters of GET /log, sorted by total, name or the count of a level, eg "?sort=level:ERROR&order=desc"
+func (p ProjectLogStatsHandler) get(r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	query := r.URL.Query()
+	filter, err := logFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.Db.GetProjectLogStats(userId, filter, query.Get("sort"), query.Get("order"))
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		p.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
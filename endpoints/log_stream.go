@@ -0,0 +1,237 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// A comment is sent this often when no logs arrive, so proxies don't close an idle tail and clients notice a dead one
+const STREAM_HEARTBEAT_INTERVAL = 15 * time.Second
+
+// How long clients wait before reconnecting a dropped tail, in milliseconds
+const STREAM_RETRY_MILLISECONDS = 3000
+
+type LogStreamHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (l LogStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "text/event-stream":
+		l.ServeEventStream(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (l LogStreamHandler) ServeEventStream(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		err := l.stream(w, r)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+		}
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Where a tail is up to, the last log it sent in (commit_seq, id) order
+type streamPosition struct {
+	commitSeq int64
+	id        string
+	cursor    string
+}
+
+func (p *streamPosition) advance(log db.Log) {
+	if log.CommitSeq == nil {
+		return
+	}
+	if *log.CommitSeq < p.commitSeq || *log.CommitSeq == p.commitSeq && log.Id <= p.id {
+		return
+	}
+	p.commitSeq = *log.CommitSeq
+	p.id = log.Id
+	p.cursor = db.StreamCursor(log)
+}
+
+// Streams logs matching the filters of GET /log as they're written, as "log" events whose data is the log's json.
+// A client reconnecting with the Last-Event-ID header, or a last_event_id parameter, first gets the logs it missed.
+// Errors before the stream starts are returned, later ones end it with an "error" event.
+func (l LogStreamHandler) stream(w http.ResponseWriter, r *http.Request) error {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	query := r.URL.Query()
+	filter, err := logFilter(query)
+	if err != nil {
+		return err
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New(error_msgs.STREAMING_UNSUPPORTED)
+	}
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = query.Get("last_event_id")
+	}
+	if cursor == "" {
+		cursor, err = l.Db.StreamCursorNow()
+		if err != nil {
+			return err
+		}
+	}
+	// Subscribing before reading the backlog means nothing written in between is lost, at worst it's read twice
+	subscription := l.Db.SubscribeLogs()
+	defer l.Db.UnsubscribeLogs(subscription)
+	backlog, next, err := l.Db.GetLogsSince(userId, filter, cursor)
+	if err != nil {
+		return err
+	}
+	readable, err := l.readableProjects(userId)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stops nginx holding events back in its buffer
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", STREAM_RETRY_MILLISECONDS)
+	position := streamPosition{}
+	// Ids sent since the last heartbeat, so logs read by both the backlog and a notice are only sent once
+	sent := map[string]bool{}
+	send := func(logs []db.Log) error {
+		for _, log := range logs {
+			if sent[log.Id] {
+				continue
+			}
+			data, err := json.Marshal(log)
+			if err != nil {
+				l.Logger.Println(err)
+				return errors.New(error_msgs.JSON_PARSING_ERROR)
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: log\ndata: %s\n\n", db.StreamCursor(log), data)
+			if err != nil {
+				return err
+			}
+			sent[log.Id] = true
+			position.advance(log)
+		}
+		flusher.Flush()
+		return nil
+	}
+	fail := func(err error) {
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", error_msgs.JsonifyError(err.Error()))
+		flusher.Flush()
+	}
+	// Sends a page of logs read from the database and the pages after it, until there are no more
+	replay := func(logs []db.Log, next string) error {
+		for {
+			err := send(logs)
+			if err != nil || next == "" {
+				return err
+			}
+			logs, next, err = l.Db.GetLogsSince(userId, filter, next)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// A tail that fell behind and had notices dropped reads what it missed from the database
+	catchUp := func() error {
+		if !subscription.CatchUp() {
+			return nil
+		}
+		since := cursor
+		if position.cursor != "" {
+			since = position.cursor
+		}
+		logs, next, err := l.Db.GetLogsSince(userId, filter, since)
+		if err != nil {
+			return err
+		}
+		return replay(logs, next)
+	}
+	err = replay(backlog, next)
+	if err != nil {
+		fail(err)
+		return nil
+	}
+	heartbeat := time.NewTicker(STREAM_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case notices := <-subscription.Notices:
+			// Everything already queued is read together so a burst of writes costs one query.
+			// Batches are shared with the other subscribers, so they're copied rather than appended to.
+			notices = append([]db.LogNotice{}, notices...)
+			for len(subscription.Notices) > 0 {
+				notices = append(notices, <-subscription.Notices...)
+			}
+			ids := []string{}
+			for _, notice := range notices {
+				if notice.ProjectId == "" || readable[notice.ProjectId] {
+					ids = append(ids, notice.Id)
+				}
+			}
+			logs, err := l.Db.GetStreamLogs(userId, filter, ids)
+			if err != nil {
+				fail(err)
+				return nil
+			}
+			err = send(logs)
+			if err == nil {
+				err = catchUp()
+			}
+			if err != nil {
+				fail(err)
+				return nil
+			}
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return nil
+			}
+			flusher.Flush()
+			clear(sent)
+			// Projects shared or unshared since the tail started apply from here on
+			readable, err = l.readableProjects(userId)
+			if err == nil {
+				err = catchUp()
+			}
+			if err != nil {
+				fail(err)
+				return nil
+			}
+		}
+	}
+}
+
+func (l LogStreamHandler) readableProjects(userId string) (map[string]bool, error) {
+	ids, err := l.Db.GetReadableProjectIds(userId)
+	if err != nil {
+		return nil, err
+	}
+	readable := map[string]bool{}
+	for _, id := range ids {
+		readable[id] = true
+	}
+	return readable, nil
+}
@@ -0,0 +1,182 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/loki"
+)
+
+const LOKI_MAX_BODY_BYTES = 16 * 1024 * 1024
+
+// Stream labels checked in order for the process and level, the first one present is used
+var LOKI_PROCESS_LABELS = []string{"process", "service_name", "app", "job"}
+var LOKI_LEVEL_LABELS = []string{"level", "detected_level", "severity", "lvl"}
+
+// Receives Loki pushes so promtail and the grafana agent can ship to a project by changing their url.
+// Credentials are the user_id and api_key headers, or basic auth with the user_id as username and api_key as password.
+type LokiPushHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (l LokiPushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = "application/x-protobuf"
+	}
+	if contentType != "application/x-protobuf" && contentType != "application/json" {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNSUPPORTED_MEDIA_TYPE), http.StatusUnsupportedMediaType)
+		return
+	}
+	rejected, message, err := l.push(w, r, contentType)
+	if err != nil {
+		status := error_msgs.GetErrorHttpStatus(err)
+		http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+		return
+	}
+	if rejected > 0 {
+		// Loki clients retry 5xx and 429 but drop other failures, which is what a rejected entry needs
+		http.Error(w, error_msgs.JsonifyError(fmt.Sprintf("%d entries rejected: %s", rejected, message)), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Writes every entry in the push, returning how many were rejected and why the first one was
+func (l LokiPushHandler) push(w http.ResponseWriter, r *http.Request, contentType string) (int, string, error) {
+	userId := r.Header.Get("user_id")
+	apiKey := r.Header.Get("api_key")
+	if username, password, ok := r.BasicAuth(); ok && userId == "" && apiKey == "" {
+		userId = username
+		apiKey = password
+	}
+	if userId == "" {
+		return 0, "", errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	if apiKey == "" {
+		return 0, "", errors.New(error_msgs.API_KEY_REQUIRED)
+	}
+	// Checked before the body is read so unauthenticated pushes can't make the server decompress and decode them
+	projectId, err := l.Db.GetApiKeyProjectId(userId, apiKey)
+	if err != nil {
+		return 0, "", err
+	}
+	body, err := readIngestBody(w, r, LOKI_MAX_BODY_BYTES)
+	if err != nil {
+		return 0, "", err
+	}
+	var req loki.PushRequest
+	if contentType == "application/x-protobuf" {
+		req, err = loki.DecodePushProto(body)
+		if err != nil {
+			l.Logger.Println(err)
+			return 0, "", errors.New(error_msgs.PROTOBUF_PARSING_ERROR)
+		}
+	} else {
+		req, err = loki.DecodePushJson(body)
+		if err != nil {
+			l.Logger.Println(err)
+			return 0, "", errors.New(error_msgs.JSON_PARSING_ERROR)
+		}
+	}
+	entries := l.logEntries(projectId, req)
+	if len(entries) == 0 {
+		return 0, "", nil
+	}
+	results, err := l.Db.WriteLogs(userId, projectId, entries)
+	if err != nil {
+		return 0, "", err
+	}
+	rejected := 0
+	var message string
+	for _, result := range results {
+		if result.Error == nil {
+			continue
+		}
+		if rejected == 0 {
+			message = *result.Error
+		}
+		rejected++
+	}
+	return rejected, message, nil
+}
+
+// Maps Loki entries onto logs: the project_id, process and level labels pick the log's columns
+// and the line becomes the message. Every other label and the structured metadata are kept as attributes.
+func (l LokiPushHandler) logEntries(projectId string, req loki.PushRequest) []db.LogEntry {
+	entries := make([]db.LogEntry, 0, req.Count())
+	for _, stream := range req.Streams {
+		labels := make(map[string]string, len(stream.Labels))
+		for key, value := range stream.Labels {
+			labels[key] = value
+		}
+		streamProjectId := projectId
+		if value, ok := labels["project_id"]; ok {
+			// A mismatch is rejected per entry by the usual validation
+			streamProjectId = value
+			delete(labels, "project_id")
+		}
+		var process *string
+		if name := takeLabel(labels, LOKI_PROCESS_LABELS); name != "" {
+			process = &name
+		}
+		// Names that are neither built in nor one of the project's levels fall back to INFO
+		var level *string
+		if name := takeLabel(labels, LOKI_LEVEL_LABELS); name != "" {
+			level = &name
+		}
+		for _, e := range stream.Entries {
+			attributes := make(map[string]any, len(labels)+len(e.StructuredMetadata))
+			for key, value := range labels {
+				attributes[key] = value
+			}
+			for key, value := range e.StructuredMetadata {
+				attributes[key] = value
+			}
+			entry := db.LogEntry{
+				ProjectId:  streamProjectId,
+				LevelId:    db.LEVEL_INFO,
+				Level:      level,
+				Process:    process,
+				Message:    e.Line,
+				Attributes: attributes,
+			}
+			// Trace ids are commonly sent as structured metadata so logs can link to traces
+			if traceId, ok := attributes["trace_id"].(string); ok && db.ValidTraceId(traceId) {
+				entry.TraceId = &traceId
+				delete(attributes, "trace_id")
+				if spanId, ok := attributes["span_id"].(string); ok && db.ValidSpanId(spanId) {
+					entry.SpanId = &spanId
+					delete(attributes, "span_id")
+				}
+			}
+			if !e.Timestamp.IsZero() {
+				timestamp := e.Timestamp
+				entry.Timestamp = &timestamp
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Removes and returns the first of keys present in labels
+func takeLabel(labels map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value, ok := labels[key]; ok && value != "" {
+			delete(labels, key)
+			return value
+		}
+	}
+	return ""
+}
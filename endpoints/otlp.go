@@ -0,0 +1,211 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+	"github.com/jesses-code-adventures/every_log/otlp"
+)
+
+const OTLP_MAX_BODY_BYTES = 16 * 1024 * 1024
+
+// Receives OTLP/HTTP log exports so OpenTelemetry SDKs can write straight to a project.
+// Exporters can't hold a session, so the user_id and api_key headers are the only credentials,
+// eg OTEL_EXPORTER_OTLP_LOGS_HEADERS="user_id=...,api_key=..."
+type OtlpLogsHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (o OtlpLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/x-protobuf" && contentType != "application/json" {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNSUPPORTED_MEDIA_TYPE), http.StatusUnsupportedMediaType)
+		return
+	}
+	rejected, message, err := o.export(w, r, contentType)
+	if err != nil {
+		status := error_msgs.GetErrorHttpStatus(err)
+		http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if contentType == "application/x-protobuf" {
+		w.Write(otlp.EncodeLogsResponseProto(rejected, message))
+		return
+	}
+	w.Write(otlp.EncodeLogsResponseJson(rejected, message))
+}
+
+// Writes every record in the export, returning how many were rejected and why the first one was
+func (o OtlpLogsHandler) export(w http.ResponseWriter, r *http.Request, contentType string) (int64, string, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return 0, "", errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	apiKey := r.Header.Get("api_key")
+	if apiKey == "" {
+		return 0, "", errors.New(error_msgs.API_KEY_REQUIRED)
+	}
+	// The key is checked before the body is read so unauthenticated clients can't make the server decode anything
+	projectId, err := o.Db.GetApiKeyProjectId(userId, apiKey)
+	if err != nil {
+		return 0, "", err
+	}
+	body, err := readIngestBody(w, r, OTLP_MAX_BODY_BYTES)
+	if err != nil {
+		return 0, "", err
+	}
+	var req otlp.LogsRequest
+	if contentType == "application/x-protobuf" {
+		req, err = otlp.DecodeLogsProto(body)
+		if err != nil {
+			o.Logger.Println(err)
+			return 0, "", errors.New(error_msgs.PROTOBUF_PARSING_ERROR)
+		}
+	} else {
+		req, err = otlp.DecodeLogsJson(body)
+		if err != nil {
+			o.Logger.Println(err)
+			return 0, "", errors.New(error_msgs.JSON_PARSING_ERROR)
+		}
+	}
+	entries := o.logEntries(projectId, req)
+	if len(entries) == 0 {
+		return 0, "", nil
+	}
+	results, err := o.Db.WriteLogs(userId, projectId, entries)
+	if err != nil {
+		return 0, "", err
+	}
+	var rejected int64
+	var message string
+	for _, result := range results {
+		if result.Error == nil {
+			continue
+		}
+		if rejected == 0 {
+			message = *result.Error
+		}
+		rejected++
+	}
+	return rejected, message, nil
+}
+
+// Maps OTLP records onto logs: severity to level, service.name to process and body to message.
+// Resource attributes are kept with a "resource." prefix alongside the record's own attributes.
+func (o OtlpLogsHandler) logEntries(projectId string, req otlp.LogsRequest) []db.LogEntry {
+	entries := make([]db.LogEntry, 0, req.Count())
+	for _, resource := range req.ResourceLogs {
+		var process *string
+		if name, ok := resource.Attributes["service.name"].(string); ok && name != "" {
+			process = &name
+		}
+		for _, scope := range resource.ScopeLogs {
+			for _, record := range scope.LogRecords {
+				attributes := make(map[string]any, len(record.Attributes)+len(resource.Attributes)+5)
+				for key, value := range resource.Attributes {
+					attributes["resource."+key] = value
+				}
+				for key, value := range record.Attributes {
+					attributes[key] = value
+				}
+				if scope.ScopeName != "" {
+					attributes["otel.scope.name"] = scope.ScopeName
+				}
+				if scope.ScopeVersion != "" {
+					attributes["otel.scope.version"] = scope.ScopeVersion
+				}
+				if record.SeverityText != "" {
+					attributes["otel.severity_text"] = record.SeverityText
+				}
+				entry := db.LogEntry{
+					ProjectId:  projectId,
+					LevelId:    otlpLevelId(record),
+					Process:    process,
+					Message:    otlpMessage(record),
+					Attributes: attributes,
+					Timestamp:  otlpTimestamp(record),
+				}
+				// Ids that aren't valid W3C trace context, such as the all zero ids of records outside a trace, are dropped
+				if db.ValidTraceId(record.TraceId) {
+					traceId := record.TraceId
+					entry.TraceId = &traceId
+					if db.ValidSpanId(record.SpanId) {
+						spanId := record.SpanId
+						entry.SpanId = &spanId
+					}
+				}
+				if stacktrace, ok := record.Attributes["exception.stacktrace"].(string); ok && stacktrace != "" {
+					entry.Traceback = &stacktrace
+					delete(attributes, "exception.stacktrace")
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+// The time the event happened, falling back to when the collector observed it
+func otlpTimestamp(record otlp.LogRecord) *time.Time {
+	nanos := record.TimeUnixNano
+	if nanos == 0 {
+		nanos = record.ObservedTimeUnixNano
+	}
+	if nanos == 0 {
+		return nil
+	}
+	timestamp := time.Unix(0, int64(nanos)).UTC()
+	return &timestamp
+}
+
+func otlpLevelId(record otlp.LogRecord) int {
+	switch {
+	case record.SeverityNumber >= otlp.SEVERITY_FATAL:
+		return db.LEVEL_CRITICAL
+	case record.SeverityNumber >= otlp.SEVERITY_ERROR:
+		return db.LEVEL_ERROR
+	case record.SeverityNumber >= otlp.SEVERITY_WARN:
+		return db.LEVEL_WARNING
+	case record.SeverityNumber >= otlp.SEVERITY_INFO:
+		return db.LEVEL_INFO
+	case record.SeverityNumber >= otlp.SEVERITY_TRACE:
+		return db.LEVEL_DEBUG
+	}
+	if levelId, ok := db.LevelIdFromName(record.SeverityText); ok {
+		return levelId
+	}
+	return db.LEVEL_INFO
+}
+
+// String bodies are used as is, structured bodies are stored as JSON.
+// Records without a body fall back to the exception message or event name.
+func otlpMessage(record otlp.LogRecord) string {
+	switch body := record.Body.(type) {
+	case string:
+		if body != "" {
+			return body
+		}
+	case nil:
+	default:
+		b, err := json.Marshal(body)
+		if err == nil {
+			return string(b)
+		}
+	}
+	if message, ok := record.Attributes["exception.message"].(string); ok && message != "" {
+		return message
+	}
+	return record.EventName
+}
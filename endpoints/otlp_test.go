@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/otlp"
+)
+
+func TestOtlpLogEntriesTimestamp(t *testing.T) {
+	eventTime := time.Date(2024, 5, 8, 12, 0, 0, 500, time.UTC)
+	observedTime := time.Date(2024, 5, 8, 12, 0, 3, 0, time.UTC)
+	tests := []struct {
+		name   string
+		record otlp.LogRecord
+		want   *time.Time
+	}{
+		{"event time", otlp.LogRecord{TimeUnixNano: uint64(eventTime.UnixNano()), ObservedTimeUnixNano: uint64(observedTime.UnixNano())}, &eventTime},
+		{"observed time without an event time", otlp.LogRecord{ObservedTimeUnixNano: uint64(observedTime.UnixNano())}, &observedTime},
+		{"neither", otlp.LogRecord{}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.record.Body = "hello"
+			req := otlp.LogsRequest{ResourceLogs: []otlp.ResourceLogs{{ScopeLogs: []otlp.ScopeLogs{{LogRecords: []otlp.LogRecord{test.record}}}}}}
+			entries := OtlpLogsHandler{}.logEntries("project", req)
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+			got := entries[0].Timestamp
+			if (got == nil) != (test.want == nil) || got != nil && !got.Equal(*test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOtlpLogEntriesTraceContext(t *testing.T) {
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const spanId = "00f067aa0ba902b7"
+	tests := []struct {
+		name        string
+		record      otlp.LogRecord
+		wantTraceId *string
+		wantSpanId  *string
+	}{
+		{"trace and span", otlp.LogRecord{TraceId: traceId, SpanId: spanId}, ptr(traceId), ptr(spanId)},
+		{"trace without a span", otlp.LogRecord{TraceId: traceId}, ptr(traceId), nil},
+		{"outside a trace", otlp.LogRecord{TraceId: "00000000000000000000000000000000", SpanId: "0000000000000000"}, nil, nil},
+		{"span without a trace", otlp.LogRecord{SpanId: spanId}, nil, nil},
+		{"malformed ids", otlp.LogRecord{TraceId: "4bf92f35", SpanId: spanId}, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.record.Body = "hello"
+			req := otlp.LogsRequest{ResourceLogs: []otlp.ResourceLogs{{ScopeLogs: []otlp.ScopeLogs{{LogRecords: []otlp.LogRecord{test.record}}}}}}
+			entry := OtlpLogsHandler{}.logEntries("project", req)[0]
+			if !equalPtr(entry.TraceId, test.wantTraceId) {
+				t.Errorf("got trace_id %v, want %v", deref(entry.TraceId), deref(test.wantTraceId))
+			}
+			if !equalPtr(entry.SpanId, test.wantSpanId) {
+				t.Errorf("got span_id %v, want %v", deref(entry.SpanId), deref(test.wantSpanId))
+			}
+			if _, ok := entry.Attributes["trace_id"]; ok {
+				t.Error("trace_id is still copied into the attributes")
+			}
+			if _, ok := entry.Attributes["span_id"]; ok {
+				t.Error("span_id is still copied into the attributes")
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
+
+func equalPtr(a *string, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,66 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+type ProcessHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (p ProcessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		p.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (p ProcessHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		processes, err := p.get(r)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(processes)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Lists the processes of the project given by the project_id query parameter
+func (p ProcessHandler) get(r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	projectId := r.URL.Query().Get("project_id")
+	if projectId == "" {
+		return nil, errors.New(error_msgs.GetRequiredMessage("project_id"))
+	}
+	resp, err := p.Db.GetProcesses(userId, projectId)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		p.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
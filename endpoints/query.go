@@ -0,0 +1,119 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Every value of a query parameter. Multi-select filters can repeat the parameter or separate values with commas,
+// eg "?level=ERROR&level=CRITICAL" or "?level=ERROR,CRITICAL".
+func queryValues(query url.Values, key string) []string {
+	values := []string{}
+	for _, value := range query[key] {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func queryInts(query url.Values, key string) ([]int, error) {
+	values := queryValues(query, key)
+	ints := make([]int, len(values))
+	for i, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.New(error_msgs.GetInvalidMessage(key))
+		}
+		ints[i] = n
+	}
+	return ints, nil
+}
+
+func queryInt(query url.Values, key string) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New(error_msgs.GetInvalidMessage(key))
+	}
+	return n, nil
+}
+
+func queryFloat(query url.Values, key string) (float64, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, errors.New(error_msgs.GetInvalidMessage(key))
+	}
+	return n, nil
+}
+
+// Parses an RFC 3339 time, nil when the parameter isn't set
+func queryTime(query url.Values, key string) (*time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, errors.New(error_msgs.GetInvalidMessage(key))
+	}
+	return &t, nil
+}
+
+// Reads the log filters shared by GET /log and the stats endpoints, eg
+// "?project_id=...&level=ERROR&from=2024-05-01T00:00:00Z&query=process:worker&q=timeout"
+func logFilter(query url.Values) (db.LogFilter, error) {
+	filter := db.LogFilter{
+		ProjectIds: queryValues(query, "project_id"),
+		Levels:     queryValues(query, "level"),
+		ProcessIds: queryValues(query, "process_id"),
+		IssueIds:   queryValues(query, "issue_id"),
+		OrgIds:     queryValues(query, "org_id"),
+		TraceIds:   queryValues(query, "trace_id"),
+		Query:      query.Get("query"),
+		Search:     query.Get("q"),
+		Match:      query.Get("match"),
+	}
+	var err error
+	filter.LevelIds, err = queryInts(query, "level_id")
+	if err != nil {
+		return filter, err
+	}
+	filter.From, err = queryTime(query, "from")
+	if err != nil {
+		return filter, err
+	}
+	filter.To, err = queryTime(query, "to")
+	if err != nil {
+		return filter, err
+	}
+	filter.Similarity, err = queryFloat(query, "similarity")
+	if err != nil {
+		return filter, err
+	}
+	// Attribute filters keep their JSON form so values stay typed, eg attributes=[{"key":"customer_id","op":"eq","value":42}]
+	if attributes := query.Get("attributes"); attributes != "" {
+		err = json.Unmarshal([]byte(attributes), &filter.Attributes)
+		if err != nil {
+			return filter, errors.New(error_msgs.GetInvalidMessage("attributes"))
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,267 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+type SavedSearchesHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (s SavedSearchesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		s.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (s SavedSearchesHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	var resp []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = s.get(r)
+	case http.MethodPost:
+		resp, err = s.create(r)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		status := error_msgs.GetErrorHttpStatus(err)
+		http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// Lists the searches the user saved or that are shared with them
+func (s SavedSearchesHandler) get(r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	resp, err := s.Db.GetSavedSearches(userId)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		s.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+// Saves the search in the body, eg {"name": "Checkout errors", "project_id": "...", "query": "level>=ERROR process:checkout*", "range": "24h"}
+func (s SavedSearchesHandler) create(r *http.Request) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	search, err := decodeSavedSearch(r, s.Logger)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Db.CreateSavedSearch(userId, search)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		s.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+type SavedSearchHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (s SavedSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		s.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (s SavedSearchHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	searchId := r.PathValue("search_id")
+	if searchId == "" {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.GetRequiredMessage("search_id")), http.StatusBadRequest)
+		return
+	}
+	var resp []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		resp, err = s.get(r, searchId)
+	case http.MethodPut:
+		resp, err = s.update(r, searchId)
+	case http.MethodDelete:
+		err = s.delete(r, searchId)
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		status := error_msgs.GetErrorHttpStatus(err)
+		http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+func (s SavedSearchHandler) get(r *http.Request, searchId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	resp, err := s.Db.GetSavedSearch(userId, searchId)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		s.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+// Replaces the search with the one in the body, in the same form as POST /search
+func (s SavedSearchHandler) update(r *http.Request, searchId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	search, err := decodeSavedSearch(r, s.Logger)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Db.UpdateSavedSearch(userId, searchId, search)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		s.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
+
+func (s SavedSearchHandler) delete(r *http.Request, searchId string) error {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	return s.Db.DeleteSavedSearch(userId, searchId)
+}
+
+func decodeSavedSearch(r *http.Request, logger *log.Logger) (db.SavedSearch, error) {
+	body := r.Body
+	defer body.Close()
+	var search db.SavedSearch
+	err := json.NewDecoder(body).Decode(&search)
+	if err != nil {
+		logger.Println(err)
+		return search, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return search, nil
+}
+
+type SavedSearchLogsHandler struct {
+	Db     *db.Db
+	Logger *log.Logger
+}
+
+func (s SavedSearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	switch accept {
+	case "application/json":
+		s.ServeJson(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+}
+
+func (s SavedSearchLogsHandler) ServeJson(w http.ResponseWriter, r *http.Request) {
+	searchId := r.PathValue("search_id")
+	if searchId == "" {
+		http.Error(w, error_msgs.JsonifyError(error_msgs.GetRequiredMessage("search_id")), http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := s.get(r, searchId)
+		if err != nil {
+			status := error_msgs.GetErrorHttpStatus(err)
+			http.Error(w, error_msgs.JsonifyError(err.Error()), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	default:
+		http.Error(w, error_msgs.JsonifyError(error_msgs.UNACCEPTABLE_HTTP_METHOD), http.StatusMethodNotAllowed)
+	}
+}
+
+// Runs the saved search, returning a page of its logs, eg "?limit=50&cursor=...".
+// from and to replace the search's range, and should be passed back with the cursor to keep a relative range from moving between pages.
+func (s SavedSearchLogsHandler) get(r *http.Request, searchId string) ([]byte, error) {
+	userId := r.Header.Get("user_id")
+	if userId == "" {
+		return nil, errors.New(error_msgs.USER_ID_REQUIRED)
+	}
+	query := r.URL.Query()
+	from, err := queryTime(query, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := queryTime(query, "to")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryInt(query, "limit")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Db.RunSavedSearch(userId, searchId, from, to, limit, query.Get("cursor"))
+	if err != nil {
+		return nil, err
+	}
+	arr, err := json.Marshal(resp)
+	if err != nil {
+		s.Logger.Println(err)
+		return nil, errors.New(error_msgs.JSON_PARSING_ERROR)
+	}
+	return arr, nil
+}
@@ -1,7 +1,9 @@
 package endpoints
 
 import (
+	"compress/gzip"
 	"errors"
+	"io"
 	"net/http"
 
 	"github.com/jesses-code-adventures/every_log/error_msgs"
@@ -14,7 +16,8 @@ type EndpointHandler interface {
 
 func BasicValidateRequest(w http.ResponseWriter, r *http.Request) error {
 	accept := r.Header.Get("Accept")
-	if accept != "application/json" {
+	// Live tails are server-sent events, everything else is json
+	if accept != "application/json" && accept != "text/event-stream" {
 		http.Error(w, error_msgs.JsonifyError("Invalid Accept Header"), http.StatusBadRequest)
 		return errors.New("Accept")
 	}
@@ -35,3 +38,31 @@ func BasicValidateRequest(w http.ResponseWriter, r *http.Request) error {
 	}
 	return nil
 }
+
+// Reads a whole request body for the ingestion protocols, which send batches rather than streams.
+// Gzip encoded bodies are decompressed, and both the raw and decompressed sizes are capped at maxBytes.
+func readIngestBody(w http.ResponseWriter, r *http.Request, maxBytes int64) ([]byte, error) {
+	body := http.MaxBytesReader(w, r.Body, maxBytes)
+	defer body.Close()
+	var reader io.Reader = body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, errors.New(error_msgs.GetInvalidMessage("gzip body"))
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	b, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errors.New(error_msgs.BODY_TOO_LARGE)
+		}
+		return nil, errors.New(error_msgs.GetInvalidMessage("body"))
+	}
+	if int64(len(b)) > maxBytes {
+		return nil, errors.New(error_msgs.BODY_TOO_LARGE)
+	}
+	return b, nil
+}
@@ -22,6 +22,22 @@ const UNACCEPTABLE_HTTP_METHOD = "Unacceptable http method"
 const UNAUTHORIZED = "Unauthorized"
 const EXPIRED_TOKEN = "Expired token"
 const INVALID_TOKEN = "Invalid token"
+const BATCH_TOO_LARGE = "Batch too large"
+const PROJECT_MISMATCH = "project_id does not match api key"
+const QUEUE_FULL = "Ingestion queue full, retry later"
+const PROTOBUF_PARSING_ERROR = "Protobuf parsing error"
+const UNSUPPORTED_MEDIA_TYPE = "Unsupported content type"
+const BODY_TOO_LARGE = "Request body too large"
+const PROCESS_CONFLICT = "Only one of process and process_id can be set"
+const NOT_FOUND = "Not found"
+const TIMESTAMP_OUT_OF_RANGE = "timestamp is outside the accepted clock skew"
+const INVALID_SEARCH = "Invalid search"
+const STREAMING_UNSUPPORTED = "Streaming unsupported"
+const SHARE_CONFLICT = "Only one of project_id and org_id can be set"
+const PROCESS_LIMIT = "Project has too many processes"
+const IDEMPOTENCY_IN_PROGRESS = "A log with this id is still being written, retry later"
+const LINE_TOO_LONG = "Line too long"
+const BODY_READ_ERROR = "Error reading request body"
 
 func GetRequiredMessage(field string) string {
 	return fmt.Sprintf("%s is required", field)
@@ -31,19 +47,48 @@ func GetExistsMessage(field string) string {
 	return fmt.Sprintf("%s already exists", field)
 }
 
+func GetInvalidMessage(field string) string {
+	return fmt.Sprintf("%s is invalid", field)
+}
+
+// Describes why a search couldn't be parsed, eg "Invalid search: unterminated phrase at position 4"
+func GetSearchErrorMessage(reason string) string {
+	return fmt.Sprintf("%s: %s", INVALID_SEARCH, reason)
+}
+
 func GetErrorHttpStatus(e error) int {
 	switch e.Error() {
 	case USER_ID_REQUIRED, API_KEY_REQUIRED, USER_TOKEN_REQUIRED, AUTHORIZATION_TOKEN_REQUIRED, EXPIRED_TOKEN, INVALID_TOKEN, UNAUTHORIZED:
 		return http.StatusUnauthorized
-	case USER_EXISTS, EMAIL_EXISTS, PROJECT_EXISTS, ORG_EXISTS:
+	case USER_EXISTS, EMAIL_EXISTS, PROJECT_EXISTS, ORG_EXISTS, IDEMPOTENCY_IN_PROGRESS:
 		return http.StatusConflict
+	case BATCH_TOO_LARGE, BODY_TOO_LARGE, LINE_TOO_LONG:
+		return http.StatusRequestEntityTooLarge
+	case NOT_FOUND:
+		return http.StatusNotFound
+	case PROCESS_CONFLICT, TIMESTAMP_OUT_OF_RANGE, SHARE_CONFLICT, PROCESS_LIMIT:
+		return http.StatusUnprocessableEntity
+	case PROTOBUF_PARSING_ERROR, BODY_READ_ERROR:
+		return http.StatusBadRequest
+	case UNSUPPORTED_MEDIA_TYPE:
+		return http.StatusUnsupportedMediaType
+	case PROJECT_MISMATCH:
+		return http.StatusForbidden
+	case QUEUE_FULL:
+		return http.StatusServiceUnavailable
 	default:
 		if strings.HasSuffix(e.Error(), "is required") {
 			return http.StatusUnprocessableEntity
 		}
+		if strings.HasSuffix(e.Error(), "is invalid") {
+			return http.StatusUnprocessableEntity
+		}
 		if strings.HasSuffix(e.Error(), "already exists") {
 			return http.StatusConflict
 		}
+		if strings.HasPrefix(e.Error(), INVALID_SEARCH) {
+			return http.StatusUnprocessableEntity
+		}
 	}
 	return http.StatusInternalServerError
 }
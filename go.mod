@@ -7,3 +7,8 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 )
+
+require (
+	github.com/golang/snappy v0.0.4
+	google.golang.org/protobuf v1.34.2
+)
@@ -0,0 +1,136 @@
+package ingest
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/jesses-code-adventures/every_log/error_msgs"
+)
+
+// Logs accepted but not yet written, beyond this the queue reports itself as full
+const QUEUE_SIZE = 10000
+
+// Number of goroutines writing to the database concurrently
+const WRITERS = 4
+
+// A writer commits once it holds this many logs or the interval has passed since its first pending log
+const GROUP_COMMIT_SIZE = 500
+const GROUP_COMMIT_INTERVAL = 5 * time.Millisecond
+
+// A group commit that fails is retried this many times in all, backing off from the min to the max delay,
+// before its logs are dropped. The writer holds off meanwhile, so a database outage fills the queue and clients are told to back off.
+const FLUSH_ATTEMPTS = 5
+const FLUSH_RETRY_MIN = 100 * time.Millisecond
+const FLUSH_RETRY_MAX = 5 * time.Second
+
+// Suggested back off for clients when the queue is full
+const RETRY_AFTER_SECONDS = 1
+
+// Holds accepted logs in a bounded channel and writes them in group commits,
+// so a request never has to wait on its own transaction.
+type Queue struct {
+	Db     *db.Db
+	Logger *log.Logger
+	logs   chan db.LogEntry
+	closed bool
+	mu     sync.RWMutex
+	wg     sync.WaitGroup
+}
+
+func NewQueue(database *db.Db, logger *log.Logger) *Queue {
+	q := &Queue{
+		Db:     database,
+		Logger: logger,
+		logs:   make(chan db.LogEntry, QUEUE_SIZE),
+	}
+	for i := 0; i < WRITERS; i++ {
+		q.wg.Add(1)
+		go q.write()
+	}
+	return q
+}
+
+// Queues an entry that has been through db.PrepareLogs, returning QUEUE_FULL rather than blocking
+func (q *Queue) Enqueue(entry db.LogEntry) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return errors.New(error_msgs.QUEUE_FULL)
+	}
+	select {
+	case q.logs <- entry:
+		return nil
+	default:
+		return errors.New(error_msgs.QUEUE_FULL)
+	}
+}
+
+// Stops accepting logs and waits for the writers to flush everything already queued
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.logs)
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) write() {
+	defer q.wg.Done()
+	pending := make([]db.LogEntry, 0, GROUP_COMMIT_SIZE)
+	timer := time.NewTimer(GROUP_COMMIT_INTERVAL)
+	timer.Stop()
+	for {
+		select {
+		case entry, ok := <-q.logs:
+			if !ok {
+				q.flush(pending)
+				return
+			}
+			if len(pending) == 0 {
+				timer.Reset(GROUP_COMMIT_INTERVAL)
+			}
+			pending = append(pending, entry)
+			if len(pending) >= GROUP_COMMIT_SIZE {
+				timer.Stop()
+				q.flush(pending)
+				pending = pending[:0]
+			}
+		case <-timer.C:
+			q.flush(pending)
+			pending = pending[:0]
+		}
+	}
+}
+
+func (q *Queue) flush(entries []db.LogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	backoff := FLUSH_RETRY_MIN
+	for attempt := 1; ; attempt++ {
+		results, err := q.Db.InsertLogs(entries)
+		if err == nil {
+			for i, result := range results {
+				if result.Error != nil {
+					q.Logger.Printf("dropped queued log %s: %s", entries[i].LogId, *result.Error)
+				}
+			}
+			return
+		}
+		if attempt == FLUSH_ATTEMPTS {
+			q.Logger.Printf("dropped %d queued logs after %d attempts: %s", len(entries), attempt, err)
+			q.Db.ReleaseLogs(entries)
+			return
+		}
+		q.Logger.Printf("retrying %d queued logs in %s: %s", len(entries), backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, FLUSH_RETRY_MAX)
+	}
+}
@@ -0,0 +1,109 @@
+package issues
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jesses-code-adventures/every_log/traceback"
+)
+
+// In app frames closest to where the error was raised that are part of a fingerprint
+const FINGERPRINT_FRAMES = 3
+
+// Longest template kept as an issue's title
+const MAX_TITLE_LENGTH = 255
+
+// How an error log is grouped into an issue.
+// Logs with the same fingerprint in a project are occurrences of the same issue.
+type Fingerprint struct {
+	Hash string
+	// The message with its variable parts replaced, eg "user <num> not found"
+	Title string
+	// Exception type of the traceback, if one was recognised
+	Exception string
+	// The innermost in app frame, where the error was raised
+	Culprit string
+}
+
+// Fingerprints a log from its message template and the top in app frames of its traceback.
+// Line numbers are left out so an issue survives unrelated edits to the file it's raised in.
+func New(message string, stacks []traceback.Stack) Fingerprint {
+	template := Template(message)
+	fingerprint := Fingerprint{Title: truncate(template, MAX_TITLE_LENGTH)}
+	parts := []string{template}
+	if stack, ok := primaryStack(stacks); ok {
+		fingerprint.Exception = stack.Exception
+		parts = append(parts, stack.Exception)
+		frames := inAppFrames(stack.Frames, FINGERPRINT_FRAMES)
+		for _, frame := range frames {
+			parts = append(parts, frameKey(frame))
+		}
+		if len(frames) > 0 {
+			fingerprint.Culprit = culprit(frames[0])
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	fingerprint.Hash = hex.EncodeToString(sum[:])
+	return fingerprint
+}
+
+// The stack the error was raised from: the first with an exception, otherwise the first with frames
+func primaryStack(stacks []traceback.Stack) (traceback.Stack, bool) {
+	for _, stack := range stacks {
+		if stack.Exception != "" {
+			return stack, true
+		}
+	}
+	for _, stack := range stacks {
+		if len(stack.Frames) > 0 {
+			return stack, true
+		}
+	}
+	return traceback.Stack{}, false
+}
+
+// Up to n in app frames, innermost first. Frames are stored outermost first, so they're read from the end.
+func inAppFrames(frames []traceback.Frame, n int) []traceback.Frame {
+	inApp := []traceback.Frame{}
+	for i := len(frames) - 1; i >= 0 && len(inApp) < n; i-- {
+		if frames[i].InApp {
+			inApp = append(inApp, frames[i])
+		}
+	}
+	return inApp
+}
+
+// Identifies a frame by its function and file, falling back to the line when there's no function name
+func frameKey(frame traceback.Frame) string {
+	if frame.Function == "" {
+		return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+	}
+	return frame.Function + " " + path.Base(frame.File)
+}
+
+func culprit(frame traceback.Frame) string {
+	if frame.Function == "" {
+		return frame.File
+	}
+	if frame.File == "" {
+		return frame.Function
+	}
+	return fmt.Sprintf("%s (%s)", frame.Function, path.Base(frame.File))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,46 @@
+package issues
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Variable parts of a message, in the order they're replaced. Earlier patterns win, so a uuid
+// is replaced whole before its digits could be mistaken for numbers.
+var templatePatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+	// Only replace matches with both letters and digits, so words such as "deadline" and plain numbers are left alone
+	mixed bool
+}{
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>", false},
+	{regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://\S+`), "<url>", false},
+	{regexp.MustCompile(`\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`), "<email>", false},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>", false},
+	{regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?)?\b`), "<timestamp>", false},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<ip>", false},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b`), "<hex>", false},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8,}\b`), "<hex>", true},
+	// A unit is replaced along with its number, so "1.5s" and "200ms" are both <num>
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?[a-zA-Z]{0,3}\b`), "<num>", false},
+}
+
+// Reduces a message to its template by replacing the parts that vary between occurrences of the same error,
+// such as ids, numbers, quoted values and urls, eg `user 42 not found in "eu-west"` becomes "user <num> not found in <str>".
+func Template(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	for _, p := range templatePatterns {
+		if p.mixed {
+			message = p.pattern.ReplaceAllStringFunc(message, func(match string) string {
+				if strings.ContainsAny(match, "0123456789") && strings.IndexFunc(match, unicode.IsLetter) >= 0 {
+					return p.replacement
+				}
+				return match
+			})
+			continue
+		}
+		message = p.pattern.ReplaceAllString(message, p.replacement)
+	}
+	return message
+}
@@ -0,0 +1,41 @@
+package issues
+
+import "testing"
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"plain text", "connection refused", "connection refused"},
+		{"whitespace", "  connection\trefused\n", "connection refused"},
+		{"numeric id", "user 42 not found", "user <num> not found"},
+		{"hex id", "job 5f3a9c2e1b failed", "job <hex> failed"},
+		{"hex word", "deadline exceeded for facade", "deadline exceeded for facade"},
+		{"hex digits only", "request 12345678 failed", "request <num> failed"},
+		{"0x hex", "segfault at 0x7ffd5e8c", "segfault at <hex>"},
+		{"uuid", "project 3F2504E0-4F89-11D3-9A0C-0305E82C3301 missing", "project <uuid> missing"},
+		{"decimal", "took 1.5 seconds", "took <num> seconds"},
+		{"number with unit", "timed out after 200ms", "timed out after <num>"},
+		{"timestamp", "expired at 2024-05-08T12:00:00Z", "expired at <timestamp>"},
+		{"date", "no data for 2024-05-08", "no data for <timestamp>"},
+		{"ip and port", "dial tcp 10.0.0.12:5432: refused", "dial tcp <ip>: refused"},
+		{"url", "GET https://api.example.com/users/42?x=1 returned 500", "GET <url> returned <num>"},
+		{"email", "no account for jo.smith+test@example.co.uk", "no account for <email>"},
+		{"double quoted", `user 42 not found in "eu-west"`, "user <num> not found in <str>"},
+		{"single quoted", "key 'session:abc' missing", "key <str> missing"},
+		{"quoted uuid", `id "3f2504e0-4f89-11d3-9a0c-0305e82c3301" is invalid`, "id <str> is invalid"},
+		{"path", "open /var/data/user/42/report.csv: no such file", "open /var/data/user/<num>/report.csv: no such file"},
+		{"windows path", `open C:\logs\2024\app.log failed`, `open C:\logs\<num>\app.log failed`},
+		{"quoted path", `open "/tmp/upload-93/file.txt" failed`, "open <str> failed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Template(test.message)
+			if got != test.want {
+				t.Errorf("Template(%q) = %q, want %q", test.message, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package logquery
+
+import (
+	"fmt"
+	"time"
+)
+
+// A query that couldn't be parsed, with the byte offset of the problem in the original text
+type SyntaxError struct {
+	Message  string
+	Position int
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+// A parsed query, one of And, Or, Not, Text or Field
+type Node interface {
+	Pos() int
+}
+
+// Matches logs matching every node. Terms next to each other are ANDed.
+type And struct {
+	Nodes    []Node
+	Position int
+}
+
+// Matches logs matching any node
+type Or struct {
+	Nodes    []Node
+	Position int
+}
+
+// Matches logs that don't match Node, eg -retry or NOT process:worker
+type Not struct {
+	Node     Node
+	Position int
+}
+
+// A full text search term, eg timeout, conn* or "connection reset".
+// Raw is the term as written, in the syntax of search.ToTsquery.
+type Text struct {
+	Raw      string
+	Position int
+}
+
+// Comparisons a field can make
+const (
+	OP_EQ  = ":"
+	OP_NE  = "!="
+	OP_GT  = ">"
+	OP_GTE = ">="
+	OP_LT  = "<"
+	OP_LTE = "<="
+)
+
+// Fields of a log that can be queried. Attributes are queried as attr.<key>.
+const (
+	FIELD_LEVEL   = "level"
+	FIELD_PROCESS = "process"
+	FIELD_PROJECT = "project"
+	FIELD_TRACE   = "trace"
+	FIELD_SPAN    = "span"
+	FIELD_ISSUE   = "issue"
+	FIELD_SINCE   = "since"
+	FIELD_UNTIL   = "until"
+	FIELD_ATTR    = "attr"
+)
+
+// A comparison on a field, eg level>=WARNING, process:worker, attr.customer_id=42 or since:15m.
+// = is read as :, so both are OP_EQ.
+type Field struct {
+	Name string
+	// The attribute key of an attr field
+	Key string
+	Op  string
+	// The value as written, without quotes
+	Value  string
+	Quoted bool
+	// Set for since and until, relative values are resolved against the time given to Parse
+	Time time.Time
+	// Set for attr comparisons, the value as json: unquoted numbers, true, false and null keep their type
+	Json any
+	// An unquoted value ending in *, eg process:worker-*. Attributes use attr.key:* to check the key exists.
+	Prefix        bool
+	Position      int
+	ValuePosition int
+}
+
+func (n And) Pos() int   { return n.Position }
+func (n Or) Pos() int    { return n.Position }
+func (n Not) Pos() int   { return n.Position }
+func (n Text) Pos() int  { return n.Position }
+func (n Field) Pos() int { return n.Position }
@@ -0,0 +1,327 @@
+package logquery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Longest query accepted
+const MAX_QUERY_LENGTH = 2048
+
+// Deepest nesting of parentheses and negations, so a hostile query can't exhaust the stack
+const MAX_QUERY_DEPTH = 32
+
+type tokenKind int
+
+const (
+	TOKEN_TEXT tokenKind = iota
+	TOKEN_FIELD
+	TOKEN_AND
+	TOKEN_OR
+	TOKEN_NOT
+	TOKEN_OPEN
+	TOKEN_CLOSE
+	TOKEN_END
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	field    Field
+	position int
+}
+
+// Parses a query such as `level>=WARNING process:worker attr.customer_id=42 "timeout" -retry since:15m`.
+//
+// Terms are ANDed unless joined by OR, and can be grouped with parentheses and negated with NOT or a leading -.
+// Words and "quoted phrases" are full text searched, a trailing * matches prefixes. Fields are compared with
+// : (or =), !=, >, >=, < and <=:
+//   - level compares severity, so level>=WARNING includes ERROR, CRITICAL and more severe project levels
+//   - process and project match a name, or an id, and accept a trailing * on names
+//   - trace, span and issue match ids
+//   - attr.<key> compares a top level attribute, numbers and true, false and null unquoted keep their json type,
+//     and attr.<key>:* checks the key exists
+//   - since and until take a duration back from now such as 15m, 2h, 7d or 1w, or an RFC 3339 time or date
+//
+// Relative times are resolved against now.
+func Parse(q string, now time.Time) (Node, error) {
+	if len(q) > MAX_QUERY_LENGTH {
+		return nil, SyntaxError{Message: "query is too long", Position: MAX_QUERY_LENGTH}
+	}
+	tokens, err := tokenize(q, now)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{tokens: tokens}
+	if p.peek().kind == TOKEN_END {
+		return nil, SyntaxError{Message: "query is empty", Position: 0}
+	}
+	node, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != TOKEN_END {
+		return nil, SyntaxError{Message: "unexpected " + describe(next), Position: next.position}
+	}
+	return node, nil
+}
+
+func tokenize(q string, now time.Time) ([]token, error) {
+	tokens := []token{}
+	i := 0
+	for i < len(q) {
+		c := q[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: TOKEN_OPEN, position: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: TOKEN_CLOSE, position: i})
+			i++
+		case c == '-' && i+1 < len(q) && !isSpace(q[i+1]):
+			tokens = append(tokens, token{kind: TOKEN_NOT, position: i})
+			i++
+		case c == '"':
+			end, err := closingQuote(q, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: TOKEN_TEXT, text: q[i:end], position: i})
+			i = end
+		default:
+			start := i
+			for i < len(q) && isNameChar(q[i]) {
+				i++
+			}
+			if op := operatorAt(q, i); op != "" && i > start {
+				field, end, err := readField(q, start, i, op, now)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{kind: TOKEN_FIELD, field: field, position: start})
+				i = end
+				continue
+			}
+			for i < len(q) && !isSpace(q[i]) && q[i] != '(' && q[i] != ')' && q[i] != '"' {
+				i++
+			}
+			word := q[start:i]
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: TOKEN_AND, position: start})
+			case "OR":
+				tokens = append(tokens, token{kind: TOKEN_OR, position: start})
+			case "NOT":
+				tokens = append(tokens, token{kind: TOKEN_NOT, position: start})
+			default:
+				if strings.Trim(word, "*") == "" {
+					return nil, SyntaxError{Message: "expected a term", Position: start}
+				}
+				tokens = append(tokens, token{kind: TOKEN_TEXT, text: word, position: start})
+			}
+		}
+	}
+	return append(tokens, token{kind: TOKEN_END, position: len(q)}), nil
+}
+
+// Reads the value of the field named q[start:nameEnd], returning the field and where its value ends
+func readField(q string, start int, nameEnd int, op string, now time.Time) (Field, int, error) {
+	field := Field{Name: strings.ToLower(q[start:nameEnd]), Op: op, Position: start}
+	if field.Op == "=" {
+		field.Op = OP_EQ
+	}
+	if key, ok := strings.CutPrefix(field.Name, FIELD_ATTR+"."); ok {
+		field.Name = FIELD_ATTR
+		field.Key = q[start+len(FIELD_ATTR)+1 : nameEnd]
+		if key == "" {
+			return field, 0, SyntaxError{Message: "attr needs a key, eg attr.customer_id", Position: start}
+		}
+	}
+	i := nameEnd + len(op)
+	field.ValuePosition = i
+	if i < len(q) && q[i] == '"' {
+		end, err := closingQuote(q, i)
+		if err != nil {
+			return field, 0, err
+		}
+		field.Value = unquote(q[i:end])
+		field.Quoted = true
+		i = end
+	} else {
+		for i < len(q) && !isSpace(q[i]) && q[i] != '(' && q[i] != ')' {
+			i++
+		}
+		field.Value = q[field.ValuePosition:i]
+		if field.Value == "" {
+			return field, 0, SyntaxError{Message: fmt.Sprintf("%s needs a value after %s", field.Name, op), Position: field.ValuePosition}
+		}
+		if value, ok := strings.CutSuffix(field.Value, "*"); ok {
+			field.Value = value
+			field.Prefix = true
+		}
+	}
+	err := checkField(&field, now)
+	if err != nil {
+		return field, 0, err
+	}
+	return field, i, nil
+}
+
+// The end of the quoted string starting at q[start], just past its closing quote. Quotes inside can be escaped with a backslash.
+func closingQuote(q string, start int) (int, error) {
+	for i := start + 1; i < len(q); i++ {
+		switch q[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, SyntaxError{Message: "unterminated quote", Position: start}
+}
+
+func unquote(quoted string) string {
+	inner := quoted[1 : len(quoted)-1]
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(inner)
+}
+
+// The comparison operator at q[i], longest first so >= isn't read as >
+func operatorAt(q string, i int) string {
+	for _, op := range []string{OP_NE, OP_GTE, OP_LTE, OP_EQ, "=", OP_GT, OP_LT} {
+		if strings.HasPrefix(q[i:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-'
+}
+
+// Queries are split on ascii whitespace only, so bytes of multibyte characters are never mistaken for spaces
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != TOKEN_END {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) or(depth int) (Node, error) {
+	position := p.peek().position
+	left, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{left}
+	for p.peek().kind == TOKEN_OR {
+		p.take()
+		right, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return Or{Nodes: nodes, Position: position}, nil
+}
+
+func (p *parser) and(depth int) (Node, error) {
+	position := p.peek().position
+	left, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{left}
+	for {
+		next := p.peek()
+		if next.kind == TOKEN_AND {
+			p.take()
+		} else if next.kind == TOKEN_OR || next.kind == TOKEN_CLOSE || next.kind == TOKEN_END {
+			break
+		}
+		right, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return And{Nodes: nodes, Position: position}, nil
+}
+
+func (p *parser) unary(depth int) (Node, error) {
+	if depth > MAX_QUERY_DEPTH {
+		return nil, SyntaxError{Message: "query is nested too deeply", Position: p.peek().position}
+	}
+	if p.peek().kind == TOKEN_NOT {
+		not := p.take()
+		node, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: node, Position: not.position}, nil
+	}
+	return p.primary(depth)
+}
+
+func (p *parser) primary(depth int) (Node, error) {
+	t := p.take()
+	switch t.kind {
+	case TOKEN_TEXT:
+		return Text{Raw: t.text, Position: t.position}, nil
+	case TOKEN_FIELD:
+		return t.field, nil
+	case TOKEN_OPEN:
+		node, err := p.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.take(); closing.kind != TOKEN_CLOSE {
+			return nil, SyntaxError{Message: "unclosed parenthesis", Position: t.position}
+		}
+		return node, nil
+	}
+	return nil, SyntaxError{Message: "expected a term but found " + describe(t), Position: t.position}
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case TOKEN_AND:
+		return "AND"
+	case TOKEN_OR:
+		return "OR"
+	case TOKEN_NOT:
+		return "NOT"
+	case TOKEN_OPEN:
+		return "("
+	case TOKEN_CLOSE:
+		return ")"
+	case TOKEN_END:
+		return "end of query"
+	case TOKEN_FIELD:
+		return t.field.Name
+	}
+	return fmt.Sprintf("%q", t.text)
+}
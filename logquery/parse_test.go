@@ -0,0 +1,138 @@
+package logquery
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  Node
+	}{
+		{
+			name:  "and binds tighter than or",
+			query: "a b OR c",
+			want: Or{Nodes: []Node{
+				And{Nodes: []Node{Text{Raw: "a", Position: 0}, Text{Raw: "b", Position: 2}}, Position: 0},
+				Text{Raw: "c", Position: 7},
+			}, Position: 0},
+		},
+		{
+			name:  "or then and",
+			query: "a OR b c",
+			want: Or{Nodes: []Node{
+				Text{Raw: "a", Position: 0},
+				And{Nodes: []Node{Text{Raw: "b", Position: 5}, Text{Raw: "c", Position: 7}}, Position: 5},
+			}, Position: 0},
+		},
+		{
+			name:  "parentheses group",
+			query: "(a OR b) c",
+			want: And{Nodes: []Node{
+				Or{Nodes: []Node{Text{Raw: "a", Position: 1}, Text{Raw: "b", Position: 6}}, Position: 1},
+				Text{Raw: "c", Position: 9},
+			}, Position: 0},
+		},
+		{
+			name:  "negation",
+			query: "-retry NOT timeout",
+			want: And{Nodes: []Node{
+				Not{Node: Text{Raw: "retry", Position: 1}, Position: 0},
+				Not{Node: Text{Raw: "timeout", Position: 11}, Position: 7},
+			}, Position: 0},
+		},
+		{
+			name:  "quoted phrase and value",
+			query: `"connection reset" attr.note="say \"hi\""`,
+			want: And{Nodes: []Node{
+				Text{Raw: `"connection reset"`, Position: 0},
+				Field{Name: FIELD_ATTR, Key: "note", Op: OP_EQ, Value: `say "hi"`, Quoted: true, Json: `say "hi"`, Position: 19, ValuePosition: 29},
+			}, Position: 0},
+		},
+		{
+			name:  "fields",
+			query: "level>=WARNING attr.duration_ms>250 process:worker-*",
+			want: And{Nodes: []Node{
+				Field{Name: FIELD_LEVEL, Op: OP_GTE, Value: "WARNING", Position: 0, ValuePosition: 7},
+				Field{Name: FIELD_ATTR, Key: "duration_ms", Op: OP_GT, Value: "250", Json: float64(250), Position: 15, ValuePosition: 32},
+				Field{Name: FIELD_PROCESS, Op: OP_EQ, Value: "worker-", Prefix: true, Position: 36, ValuePosition: 44},
+			}, Position: 0},
+		},
+		{
+			name:  "attribute exists",
+			query: "attr.customer_id:*",
+			want:  Field{Name: FIELD_ATTR, Key: "customer_id", Op: OP_EQ, Prefix: true, Position: 0, ValuePosition: 17},
+		},
+		{
+			name:  "attribute literals keep their type",
+			query: "attr.retried=true",
+			want:  Field{Name: FIELD_ATTR, Key: "retried", Op: OP_EQ, Value: "true", Json: true, Position: 0, ValuePosition: 13},
+		},
+		{
+			name:  "field names ignore case and = is :",
+			query: "LEVEL=error",
+			want:  Field{Name: FIELD_LEVEL, Op: OP_EQ, Value: "error", Position: 0, ValuePosition: 6},
+		},
+		{
+			name:  "relative time",
+			query: "since:15m",
+			want:  Field{Name: FIELD_SINCE, Op: OP_EQ, Value: "15m", Time: testNow.Add(-15 * time.Minute), Position: 0, ValuePosition: 6},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse(test.query, testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  SyntaxError
+	}{
+		{"empty", "", SyntaxError{Message: "query is empty", Position: 0}},
+		{"only spaces", "   ", SyntaxError{Message: "query is empty", Position: 0}},
+		{"too long", strings.Repeat("a", MAX_QUERY_LENGTH+1), SyntaxError{Message: "query is too long", Position: MAX_QUERY_LENGTH}},
+		{"unclosed parenthesis", "(a OR b", SyntaxError{Message: "unclosed parenthesis", Position: 0}},
+		{"stray parenthesis", "a )", SyntaxError{Message: "unexpected )", Position: 2}},
+		{"unterminated quote", `level:ERROR "timeout`, SyntaxError{Message: "unterminated quote", Position: 12}},
+		{"dangling or", "a OR", SyntaxError{Message: "expected a term but found end of query", Position: 4}},
+		{"repeated and", "a AND AND b", SyntaxError{Message: "expected a term but found AND", Position: 6}},
+		{"bare wildcard", "*", SyntaxError{Message: "expected a term", Position: 0}},
+		{"nested too deeply", strings.Repeat("(", 40) + "a" + strings.Repeat(")", 40), SyntaxError{Message: "query is nested too deeply", Position: 33}},
+		{"unknown field", "colour:red", SyntaxError{Message: `unknown field "colour", expected one of level, process, project, trace, span, issue, since, until or attr.<key>`, Position: 0}},
+		{"operator the field lacks", "process>worker", SyntaxError{Message: "process can't be compared with >", Position: 7}},
+		{"missing value", "level:", SyntaxError{Message: "level needs a value after :", Position: 6}},
+		{"bad time", "a since:tomorrow", SyntaxError{Message: "since needs a duration such as 15m, 2h or 7d, or an RFC 3339 time or date", Position: 8}},
+		{"attribute without a key", "attr.:x", SyntaxError{Message: "attr needs a key, eg attr.customer_id", Position: 0}},
+		{"attribute range needs a number", "attr.n>big", SyntaxError{Message: "attr.n > needs a number", Position: 7}},
+		{"prefix on a field without prefixes", "level:ERR*", SyntaxError{Message: "level doesn't match prefixes", Position: 6}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.query, testNow)
+			var got SyntaxError
+			if !errors.As(err, &got) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package logquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operators each field accepts
+var fieldOps = map[string][]string{
+	FIELD_LEVEL:   {OP_EQ, OP_NE, OP_GT, OP_GTE, OP_LT, OP_LTE},
+	FIELD_PROCESS: {OP_EQ, OP_NE},
+	FIELD_PROJECT: {OP_EQ, OP_NE},
+	FIELD_TRACE:   {OP_EQ, OP_NE},
+	FIELD_SPAN:    {OP_EQ, OP_NE},
+	FIELD_ISSUE:   {OP_EQ, OP_NE},
+	FIELD_SINCE:   {OP_EQ},
+	FIELD_UNTIL:   {OP_EQ},
+	FIELD_ATTR:    {OP_EQ, OP_NE, OP_GT, OP_GTE, OP_LT, OP_LTE},
+}
+
+// Fields whose unquoted values can end in * to match a prefix
+var prefixFields = map[string]bool{
+	FIELD_PROCESS: true,
+	FIELD_PROJECT: true,
+}
+
+// Checks a field's operator and value, resolving times and attribute values
+func checkField(field *Field, now time.Time) error {
+	ops, ok := fieldOps[field.Name]
+	if !ok {
+		return SyntaxError{Message: fmt.Sprintf("unknown field %q, expected one of level, process, project, trace, span, issue, since, until or attr.<key>", field.Name), Position: field.Position}
+	}
+	if !contains(ops, field.Op) {
+		return SyntaxError{Message: fmt.Sprintf("%s can't be compared with %s", field.Name, field.Op), Position: field.ValuePosition - len(field.Op)}
+	}
+	if field.Name == FIELD_ATTR {
+		return checkAttribute(field)
+	}
+	if field.Prefix && !prefixFields[field.Name] {
+		return SyntaxError{Message: fmt.Sprintf("%s doesn't match prefixes", field.Name), Position: field.ValuePosition}
+	}
+	if field.Value == "" {
+		return SyntaxError{Message: fmt.Sprintf("%s needs a value", field.Name), Position: field.ValuePosition}
+	}
+	if field.Name == FIELD_SINCE || field.Name == FIELD_UNTIL {
+		t, ok := parseTime(field.Value, now)
+		if !ok {
+			return SyntaxError{Message: fmt.Sprintf("%s needs a duration such as 15m, 2h or 7d, or an RFC 3339 time or date", field.Name), Position: field.ValuePosition}
+		}
+		field.Time = t
+	}
+	return nil
+}
+
+func checkAttribute(field *Field) error {
+	if field.Prefix && field.Value == "" {
+		if field.Op != OP_EQ {
+			return SyntaxError{Message: "only attr.<key>:* checks a key exists", Position: field.ValuePosition}
+		}
+		return nil
+	}
+	if field.Prefix {
+		return SyntaxError{Message: "attr doesn't match prefixes", Position: field.ValuePosition}
+	}
+	field.Json = field.Value
+	if field.Quoted {
+		return nil
+	}
+	switch field.Value {
+	case "true":
+		field.Json = true
+	case "false":
+		field.Json = false
+	case "null":
+		field.Json = nil
+	default:
+		if n, err := strconv.ParseFloat(field.Value, 64); err == nil {
+			field.Json = n
+		}
+	}
+	if field.Op == OP_GT || field.Op == OP_GTE || field.Op == OP_LT || field.Op == OP_LTE {
+		if _, ok := field.Json.(float64); !ok {
+			return SyntaxError{Message: fmt.Sprintf("attr.%s %s needs a number", field.Key, field.Op), Position: field.ValuePosition}
+		}
+	}
+	return nil
+}
+
+// Units beyond time.ParseDuration's, which stops at hours
+var longUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// Reads a duration such as 15m, 1h30m, 7d or 2w. Days and weeks are whole numbers of 24 hour days.
+func ParseDuration(value string) (time.Duration, bool) {
+	for unit, length := range longUnits {
+		if count, ok := strings.CutSuffix(value, unit); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return 0, false
+			}
+			return time.Duration(n) * length, true
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// Reads a duration back from now, see ParseDuration, or an absolute RFC 3339 time or date
+func parseTime(value string, now time.Time) (time.Time, bool) {
+	if d, ok := ParseDuration(value); ok {
+		return now.Add(-d), true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Reads a time range such as 24h, 7d..1d or 2024-05-01..2024-05-08, whose start and optional end take what since and until do.
+// to is nil when the range runs up to now.
+func ParseRange(value string, now time.Time) (from time.Time, to *time.Time, ok bool) {
+	start, end, bounded := strings.Cut(value, "..")
+	from, ok = parseTime(strings.TrimSpace(start), now)
+	if !ok || !bounded {
+		return from, nil, ok
+	}
+	t, ok := parseTime(strings.TrimSpace(end), now)
+	if !ok || t.Before(from) {
+		return from, nil, false
+	}
+	return from, &t, true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
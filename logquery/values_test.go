@@ -0,0 +1,81 @@
+package logquery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"15m", 15 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"0d", 0, true},
+		{"1.5d", 0, false},
+		{"-2d", 0, false},
+		{"-1h", 0, false},
+		{"d", 0, false},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, ok := ParseDuration(test.value)
+			if got != test.want || ok != test.wantOk {
+				t.Errorf("got %v, %v, want %v, %v", got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	day := 24 * time.Hour
+	date := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		value    string
+		wantFrom time.Time
+		wantTo   *time.Time
+		wantOk   bool
+	}{
+		{"duration up to now", "24h", testNow.Add(-day), nil, true},
+		{"relative range", "7d..1d", testNow.Add(-7 * day), ptr(testNow.Add(-day)), true},
+		{"dates", "2024-05-01..2024-05-08", date(2024, 5, 1), ptr(date(2024, 5, 8)), true},
+		{"time up to now", "2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), nil, true},
+		{"mixed with spaces", " 2024-05-01 .. 1d ", date(2024, 5, 1), ptr(testNow.Add(-day)), true},
+		{"start equal to end", "1h..1h", testNow.Add(-time.Hour), ptr(testNow.Add(-time.Hour)), true},
+		{"end before start", "1d..7d", time.Time{}, nil, false},
+		{"missing end", "24h..", time.Time{}, nil, false},
+		{"missing start", "..1d", time.Time{}, nil, false},
+		{"not a time", "yesterday", time.Time{}, nil, false},
+		{"negative duration", "-1h", time.Time{}, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, ok := ParseRange(test.value, testNow)
+			if ok != test.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, test.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if !from.Equal(test.wantFrom) {
+				t.Errorf("got from %v, want %v", from, test.wantFrom)
+			}
+			if (to == nil) != (test.wantTo == nil) || to != nil && !to.Equal(*test.wantTo) {
+				t.Errorf("got to %v, want %v", to, test.wantTo)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
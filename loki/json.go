@@ -0,0 +1,70 @@
+package loki
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrEntry = errors.New("invalid loki entry, expected [timestamp, line] or [timestamp, line, structured metadata]")
+
+type jsonPushRequest struct {
+	Streams []struct {
+		Stream map[string]string   `json:"stream"`
+		Values [][]json.RawMessage `json:"values"`
+	} `json:"streams"`
+}
+
+// Decodes a JSON encoded PushRequest, where each value is ["<unix epoch in nanoseconds>", "<line>", optional {metadata}]
+func DecodePushJson(b []byte) (PushRequest, error) {
+	var parsed jsonPushRequest
+	err := json.Unmarshal(b, &parsed)
+	if err != nil {
+		return PushRequest{}, err
+	}
+	var req PushRequest
+	for _, s := range parsed.Streams {
+		stream := Stream{Labels: s.Stream}
+		if stream.Labels == nil {
+			stream.Labels = map[string]string{}
+		}
+		for _, value := range s.Values {
+			entry, err := jsonEntry(value)
+			if err != nil {
+				return PushRequest{}, err
+			}
+			stream.Entries = append(stream.Entries, entry)
+		}
+		req.Streams = append(req.Streams, stream)
+	}
+	return req, nil
+}
+
+func jsonEntry(value []json.RawMessage) (Entry, error) {
+	var entry Entry
+	if len(value) < 2 || len(value) > 3 {
+		return entry, ErrEntry
+	}
+	var timestamp string
+	err := json.Unmarshal(value[0], &timestamp)
+	if err != nil {
+		return entry, ErrEntry
+	}
+	nanos, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return entry, ErrEntry
+	}
+	entry.Timestamp = time.Unix(0, nanos).UTC()
+	err = json.Unmarshal(value[1], &entry.Line)
+	if err != nil {
+		return entry, ErrEntry
+	}
+	if len(value) == 3 {
+		err = json.Unmarshal(value[2], &entry.StructuredMetadata)
+		if err != nil {
+			return entry, ErrEntry
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,61 @@
+package loki
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodePushJson(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    PushRequest
+		wantErr bool
+	}{
+		{
+			name: "entries with and without metadata",
+			body: `{"streams": [{"stream": {"job": "api"}, "values": [["1715169600000000000", "started"], ["1715169601500000000", "failed", {"trace_id": "abc"}]]}]}`,
+			want: PushRequest{Streams: []Stream{{
+				Labels: map[string]string{"job": "api"},
+				Entries: []Entry{
+					{Timestamp: time.Unix(1715169600, 0).UTC(), Line: "started"},
+					{Timestamp: time.Unix(1715169601, 500000000).UTC(), Line: "failed", StructuredMetadata: map[string]string{"trace_id": "abc"}},
+				},
+			}}},
+		},
+		{
+			name: "stream without labels",
+			body: `{"streams": [{"values": [["1", "x"]]}]}`,
+			want: PushRequest{Streams: []Stream{{
+				Labels:  map[string]string{},
+				Entries: []Entry{{Timestamp: time.Unix(0, 1).UTC(), Line: "x"}},
+			}}},
+		},
+		{name: "no streams", body: `{"streams": []}`, want: PushRequest{}},
+		{name: "numeric timestamp", body: `{"streams": [{"values": [[1715169600000000000, "x"]]}]}`, wantErr: true},
+		{name: "timestamp not a number", body: `{"streams": [{"values": [["yesterday", "x"]]}]}`, wantErr: true},
+		{name: "missing line", body: `{"streams": [{"values": [["1"]]}]}`, wantErr: true},
+		{name: "extra element", body: `{"streams": [{"values": [["1", "x", {}, "y"]]}]}`, wantErr: true},
+		{name: "line not a string", body: `{"streams": [{"values": [["1", 42]]}]}`, wantErr: true},
+		{name: "metadata not an object", body: `{"streams": [{"values": [["1", "x", "trace_id=abc"]]}]}`, wantErr: true},
+		{name: "not json", body: `streams`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DecodePushJson([]byte(test.body))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
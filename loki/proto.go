@@ -0,0 +1,128 @@
+package loki
+
+import (
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/jesses-code-adventures/every_log/pbwire"
+)
+
+// Decodes a snappy compressed, protobuf encoded PushRequest as sent by promtail and the grafana agent
+func DecodePushProto(b []byte) (PushRequest, error) {
+	var req PushRequest
+	decoded, err := snappy.Decode(nil, b)
+	if err != nil {
+		return req, err
+	}
+	err = pbwire.EachField(decoded, func(f pbwire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		msg, err := f.Message()
+		if err != nil {
+			return err
+		}
+		stream, err := decodeStream(msg)
+		if err != nil {
+			return err
+		}
+		req.Streams = append(req.Streams, stream)
+		return nil
+	})
+	return req, err
+}
+
+func decodeStream(b []byte) (Stream, error) {
+	var stream Stream
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		switch f.Num {
+		case 1:
+			labels, err := f.String()
+			if err != nil {
+				return err
+			}
+			stream.Labels, err = ParseLabels(labels)
+			return err
+		case 2:
+			msg, err := f.Message()
+			if err != nil {
+				return err
+			}
+			entry, err := decodeEntry(msg)
+			if err != nil {
+				return err
+			}
+			stream.Entries = append(stream.Entries, entry)
+		}
+		return nil
+	})
+	if err == nil && stream.Labels == nil {
+		err = ErrLabels
+	}
+	return stream, err
+}
+
+func decodeEntry(b []byte) (Entry, error) {
+	var entry Entry
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			var msg []byte
+			msg, err = f.Message()
+			if err != nil {
+				return err
+			}
+			entry.Timestamp, err = decodeTimestamp(msg)
+		case 2:
+			entry.Line, err = f.String()
+		case 3:
+			var msg []byte
+			msg, err = f.Message()
+			if err != nil {
+				return err
+			}
+			if entry.StructuredMetadata == nil {
+				entry.StructuredMetadata = map[string]string{}
+			}
+			err = decodeLabelPairInto(msg, entry.StructuredMetadata)
+		}
+		return err
+	})
+	return entry, err
+}
+
+// Decodes a google.protobuf.Timestamp
+func decodeTimestamp(b []byte) (time.Time, error) {
+	var seconds, nanos int64
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			seconds, err = f.Int()
+		case 2:
+			nanos, err = f.Int()
+		}
+		return err
+	})
+	return time.Unix(seconds, nanos).UTC(), err
+}
+
+func decodeLabelPairInto(b []byte, into map[string]string) error {
+	var name, value string
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			name, err = f.String()
+		case 2:
+			value, err = f.String()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	into[name] = value
+	return nil
+}
@@ -0,0 +1,104 @@
+package loki
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// An EntryAdapter, with a LabelPairAdapter of structured metadata for each pair of metadata
+func protoEntry(seconds int64, nanos int64, line string, metadata ...string) []byte {
+	var timestamp []byte
+	timestamp = protowire.AppendTag(timestamp, 1, protowire.VarintType)
+	timestamp = protowire.AppendVarint(timestamp, uint64(seconds))
+	timestamp = protowire.AppendTag(timestamp, 2, protowire.VarintType)
+	timestamp = protowire.AppendVarint(timestamp, uint64(nanos))
+	entry := appendMessage(nil, 1, timestamp)
+	entry = appendString(entry, 2, line)
+	for i := 0; i+1 < len(metadata); i += 2 {
+		pair := appendString(nil, 1, metadata[i])
+		pair = appendString(pair, 2, metadata[i+1])
+		entry = appendMessage(entry, 3, pair)
+	}
+	return entry
+}
+
+func protoStream(labels string, entries ...[]byte) []byte {
+	var stream []byte
+	if labels != "" {
+		stream = appendString(stream, 1, labels)
+	}
+	for _, entry := range entries {
+		stream = appendMessage(stream, 2, entry)
+	}
+	return stream
+}
+
+func protoPush(streams ...[]byte) []byte {
+	var push []byte
+	for _, stream := range streams {
+		push = appendMessage(push, 1, stream)
+	}
+	return snappy.Encode(nil, push)
+}
+
+func TestDecodePushProto(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		want    PushRequest
+		wantErr bool
+	}{
+		{
+			name: "streams",
+			body: protoPush(
+				protoStream(`{job="api"}`, protoEntry(1715169600, 0, "started"), protoEntry(1715169601, 500000000, "failed", "trace_id", "abc")),
+				protoStream(`{job="worker", env="prod"}`, protoEntry(1715169602, 0, "idle")),
+			),
+			want: PushRequest{Streams: []Stream{
+				{Labels: map[string]string{"job": "api"}, Entries: []Entry{
+					{Timestamp: time.Unix(1715169600, 0).UTC(), Line: "started"},
+					{Timestamp: time.Unix(1715169601, 500000000).UTC(), Line: "failed", StructuredMetadata: map[string]string{"trace_id": "abc"}},
+				}},
+				{Labels: map[string]string{"job": "worker", "env": "prod"}, Entries: []Entry{
+					{Timestamp: time.Unix(1715169602, 0).UTC(), Line: "idle"},
+				}},
+			}},
+		},
+		{name: "empty push", body: protoPush(), want: PushRequest{}},
+		{name: "stream without labels", body: protoPush(protoStream("", protoEntry(1, 0, "x"))), wantErr: true},
+		{name: "invalid labels", body: protoPush(protoStream(`job="api"`, protoEntry(1, 0, "x"))), wantErr: true},
+		{name: "not snappy", body: []byte{0xff, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "truncated message", body: snappy.Encode(nil, protoStream(`{job="api"}`, protoEntry(1, 0, "x"))[:5]), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DecodePushProto(test.body)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package loki
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decoded form of a Loki PushRequest, shared by the protobuf and JSON encodings
+type PushRequest struct {
+	Streams []Stream
+}
+
+type Stream struct {
+	Labels  map[string]string
+	Entries []Entry
+}
+
+type Entry struct {
+	Timestamp time.Time
+	Line      string
+	// Per entry labels that aren't part of the stream, empty for older clients
+	StructuredMetadata map[string]string
+}
+
+// Number of entries across every stream
+func (r PushRequest) Count() int {
+	count := 0
+	for _, stream := range r.Streams {
+		count += len(stream.Entries)
+	}
+	return count
+}
+
+var ErrLabels = errors.New("invalid loki stream labels")
+
+// Parses a stream selector in the prometheus label format, eg {job="varlogs", level="info"}
+func ParseLabels(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, ErrLabels
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	labels := map[string]string{}
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, ErrLabels
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimSpace(s[eq+1:])
+		if !strings.HasPrefix(s, `"`) {
+			return nil, ErrLabels
+		}
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return nil, ErrLabels
+		}
+		value, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, ErrLabels
+		}
+		labels[name] = value
+		s = strings.TrimSpace(s[end+1:])
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if s != "" {
+			return nil, ErrLabels
+		}
+	}
+	return labels, nil
+}
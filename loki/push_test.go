@@ -0,0 +1,44 @@
+package loki
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"selector", `{job="varlogs", level="info"}`, map[string]string{"job": "varlogs", "level": "info"}, false},
+		{"empty", `{}`, map[string]string{}, false},
+		{"spaces", ` { app = "api" } `, map[string]string{"app": "api"}, false},
+		{"escaped quote", `{msg="say \"hi\""}`, map[string]string{"msg": `say "hi"`}, false},
+		{"escaped backslash", `{path="C:\\logs"}`, map[string]string{"path": `C:\logs`}, false},
+		{"no braces", `job="varlogs"`, nil, true},
+		{"no value", `{job}`, nil, true},
+		{"unquoted value", `{job=varlogs}`, nil, true},
+		{"unterminated value", `{job="varlogs}`, nil, true},
+		{"missing comma", `{job="varlogs" level="info"}`, nil, true},
+		{"empty name", `{="varlogs"}`, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseLabels(test.labels)
+			if test.wantErr {
+				if err != ErrLabels {
+					t.Errorf("got %v, want %v", err, ErrLabels)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
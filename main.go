@@ -1,25 +1,88 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jesses-code-adventures/every_log/db"
 	"github.com/jesses-code-adventures/every_log/endpoints"
+	"github.com/jesses-code-adventures/every_log/ingest"
+	"github.com/jesses-code-adventures/every_log/syslog"
 )
 
+// How long in flight requests get to finish on shutdown before the queue is drained regardless
+const SHUTDOWN_TIMEOUT = 30 * time.Second
+
 func main() {
 	logger := log.New(os.Stdout, "", log.LstdFlags|log.Llongfile)
 	db := db.NewDb(logger)
 	defer db.Close()
+	queue := ingest.NewQueue(&db, logger)
+	defer queue.Close()
+	listeners, err := syslog.ListenersFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	syslogServer := syslog.NewServer(&db, logger, listeners)
+	err = syslogServer.Start()
+	if err != nil {
+		panic(err)
+	}
+	defer syslogServer.Close()
 	mux := http.NewServeMux()
-	handler := endpoints.NewServerHandler(&db, logger)
+	handler := endpoints.NewServerHandler(&db, queue, logger)
 	mux.Handle("/project/{project_id}/key", endpoints.ApiKeyHandler{Db: &db, Logger: logger})
 	mux.Handle("/project/{project_id}/invite", endpoints.ProjectInviteHandler{Db: &db, Logger: logger})
+	mux.Handle("/project/{project_id}/level", handler.Authorized(endpoints.ProjectLevelHandler{Db: &db, Logger: logger}))
+	mux.Handle("/project/{project_id}/issues", handler.Authorized(endpoints.ProjectIssuesHandler{Db: &db, Logger: logger}))
+	mux.Handle("/issue/{issue_id}", handler.Authorized(endpoints.IssueHandler{Db: &db, Logger: logger}))
+	mux.Handle("/log/batch", handler.Authorized(endpoints.LogBatchHandler{Db: &db, Logger: logger}))
+	mux.Handle("/log/stream", handler.Authorized(endpoints.LogStreamHandler{Db: &db, Logger: logger}))
+	mux.Handle("/log/stats", handler.Authorized(endpoints.LogStatsHandler{Db: &db, Logger: logger}))
+	mux.Handle("/log/stats/projects", handler.Authorized(endpoints.ProjectLogStatsHandler{Db: &db, Logger: logger}))
+	mux.Handle("/filterItems", handler.Authorized(endpoints.FilterItemsHandler{Db: &db, Logger: logger}))
+	mux.Handle("/search", handler.Authorized(endpoints.SavedSearchesHandler{Db: &db, Logger: logger}))
+	mux.Handle("/search/{search_id}", handler.Authorized(endpoints.SavedSearchHandler{Db: &db, Logger: logger}))
+	mux.Handle("/search/{search_id}/logs", handler.Authorized(endpoints.SavedSearchLogsHandler{Db: &db, Logger: logger}))
+	mux.Handle("/log/{log_id}", handler.Authorized(endpoints.LogDetailHandler{Db: &db, Logger: logger}))
+	mux.Handle("/trace/{trace_id}", handler.Authorized(endpoints.TraceHandler{Db: &db, Logger: logger}))
+	mux.Handle("/v1/logs", endpoints.OtlpLogsHandler{Db: &db, Logger: logger})
+	mux.Handle("/loki/api/v1/push", endpoints.LokiPushHandler{Db: &db, Logger: logger})
 	mux.Handle("/", &handler)
-	err := http.ListenAndServe(":8080", mux)
+	// Cancelled on shutdown so live tails, which never finish on their own, end rather than hold it up
+	requests, cancelRequests := context.WithCancel(context.Background())
+	server := &http.Server{
+		Addr:        ":8080",
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return requests },
+	}
+	server.RegisterOnShutdown(cancelRequests)
+	stop, cancelStop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancelStop()
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	select {
+	case err = <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	case <-stop.Done():
+	}
+	// Stops accepting requests and waits for those in flight, then the deferred closes drain the queue before the database closes
+	logger.Println("shutting down")
+	shutdown, cancelShutdown := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancelShutdown()
+	err = server.Shutdown(shutdown)
 	if err != nil {
-		panic(err)
+		logger.Println(err)
 	}
 }
@@ -0,0 +1,185 @@
+package otlp
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// OTLP/JSON writes 64 bit integers as strings, but some SDKs send them as numbers
+type jsonUint64 uint64
+
+func (n *jsonUint64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	*n = jsonUint64(v)
+	return err
+}
+
+type jsonInt64 int64
+
+func (n *jsonInt64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	*n = jsonInt64(v)
+	return err
+}
+
+type jsonKeyValue struct {
+	Key   string       `json:"key"`
+	Value jsonAnyValue `json:"value"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string    `json:"stringValue"`
+	BoolValue   *bool      `json:"boolValue"`
+	IntValue    *jsonInt64 `json:"intValue"`
+	DoubleValue *float64   `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []jsonAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []jsonKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	// encoding/json decodes base64 into []byte, as OTLP/JSON requires
+	BytesValue []byte `json:"bytesValue"`
+}
+
+type jsonLogsRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano         jsonUint64     `json:"timeUnixNano"`
+				ObservedTimeUnixNano jsonUint64     `json:"observedTimeUnixNano"`
+				SeverityNumber       int32          `json:"severityNumber"`
+				SeverityText         string         `json:"severityText"`
+				Body                 *jsonAnyValue  `json:"body"`
+				Attributes           []jsonKeyValue `json:"attributes"`
+				Flags                uint32         `json:"flags"`
+				TraceId              string         `json:"traceId"`
+				SpanId               string         `json:"spanId"`
+				EventName            string         `json:"eventName"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+// Decodes a JSON encoded ExportLogsServiceRequest
+func DecodeLogsJson(b []byte) (LogsRequest, error) {
+	var parsed jsonLogsRequest
+	err := json.Unmarshal(b, &parsed)
+	if err != nil {
+		return LogsRequest{}, err
+	}
+	var req LogsRequest
+	for _, r := range parsed.ResourceLogs {
+		attributes, err := jsonAttributes(r.Resource.Attributes, 0)
+		if err != nil {
+			return LogsRequest{}, err
+		}
+		resource := ResourceLogs{Attributes: attributes}
+		for _, s := range r.ScopeLogs {
+			scope := ScopeLogs{ScopeName: s.Scope.Name, ScopeVersion: s.Scope.Version}
+			for _, l := range s.LogRecords {
+				record := LogRecord{
+					TimeUnixNano:         uint64(l.TimeUnixNano),
+					ObservedTimeUnixNano: uint64(l.ObservedTimeUnixNano),
+					SeverityNumber:       l.SeverityNumber,
+					SeverityText:         l.SeverityText,
+					Flags:                l.Flags,
+					TraceId:              strings.ToLower(l.TraceId),
+					SpanId:               strings.ToLower(l.SpanId),
+					EventName:            l.EventName,
+				}
+				record.Attributes, err = jsonAttributes(l.Attributes, 0)
+				if err != nil {
+					return LogsRequest{}, err
+				}
+				if l.Body != nil {
+					record.Body, err = l.Body.value(0)
+					if err != nil {
+						return LogsRequest{}, err
+					}
+				}
+				scope.LogRecords = append(scope.LogRecords, record)
+			}
+			resource.ScopeLogs = append(resource.ScopeLogs, scope)
+		}
+		req.ResourceLogs = append(req.ResourceLogs, resource)
+	}
+	return req, nil
+}
+
+func jsonAttributes(kvs []jsonKeyValue, depth int) (map[string]any, error) {
+	attributes := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		value, err := kv.Value.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		attributes[kv.Key] = value
+	}
+	return attributes, nil
+}
+
+// Converts an AnyValue nested depth arrays and kvlists deep, see MAX_ANY_VALUE_DEPTH
+func (v jsonAnyValue) value(depth int) (any, error) {
+	if depth > MAX_ANY_VALUE_DEPTH {
+		return nil, errAnyValueTooDeep
+	}
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue, nil
+	case v.BoolValue != nil:
+		return *v.BoolValue, nil
+	case v.IntValue != nil:
+		return int64(*v.IntValue), nil
+	case v.DoubleValue != nil:
+		return *v.DoubleValue, nil
+	case v.ArrayValue != nil:
+		values := make([]any, len(v.ArrayValue.Values))
+		for i, value := range v.ArrayValue.Values {
+			var err error
+			values[i], err = value.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case v.KvlistValue != nil:
+		return jsonAttributes(v.KvlistValue.Values, depth+1)
+	case v.BytesValue != nil:
+		return v.BytesValue, nil
+	}
+	return nil, nil
+}
+
+// Encodes an ExportLogsServiceResponse, only populating partialSuccess when records were rejected
+func EncodeLogsResponseJson(rejected int64, message string) []byte {
+	if rejected == 0 && message == "" {
+		return []byte("{}")
+	}
+	resp := struct {
+		PartialSuccess struct {
+			RejectedLogRecords string `json:"rejectedLogRecords"`
+			ErrorMessage       string `json:"errorMessage"`
+		} `json:"partialSuccess"`
+	}{}
+	resp.PartialSuccess.RejectedLogRecords = strconv.FormatInt(rejected, 10)
+	resp.PartialSuccess.ErrorMessage = message
+	b, _ := json.Marshal(resp)
+	return b
+}
@@ -0,0 +1,53 @@
+package otlp
+
+// Decoded form of an OTLP ExportLogsServiceRequest, shared by the protobuf and JSON encodings.
+// AnyValues are converted to plain go values: string, bool, int64, float64, []byte, []any or map[string]any.
+type LogsRequest struct {
+	ResourceLogs []ResourceLogs
+}
+
+type ResourceLogs struct {
+	Attributes map[string]any
+	ScopeLogs  []ScopeLogs
+}
+
+type ScopeLogs struct {
+	ScopeName    string
+	ScopeVersion string
+	LogRecords   []LogRecord
+}
+
+type LogRecord struct {
+	TimeUnixNano         uint64
+	ObservedTimeUnixNano uint64
+	SeverityNumber       int32
+	SeverityText         string
+	Body                 any
+	Attributes           map[string]any
+	// Hex encoded, empty when the record isn't part of a trace
+	TraceId   string
+	SpanId    string
+	Flags     uint32
+	EventName string
+}
+
+// Number of log records across every resource and scope
+func (r LogsRequest) Count() int {
+	count := 0
+	for _, resource := range r.ResourceLogs {
+		for _, scope := range resource.ScopeLogs {
+			count += len(scope.LogRecords)
+		}
+	}
+	return count
+}
+
+// OTLP severity numbers are grouped in fours, each group shares a short name
+const (
+	SEVERITY_TRACE = 1
+	SEVERITY_DEBUG = 5
+	SEVERITY_INFO  = 9
+	SEVERITY_WARN  = 13
+	SEVERITY_ERROR = 17
+	SEVERITY_FATAL = 21
+)
@@ -0,0 +1,250 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/jesses-code-adventures/every_log/pbwire"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Deepest nesting of array and kvlist AnyValues decoded. Deeper values are rejected rather than recursed into,
+// as a small compressed body could otherwise nest deep enough to overflow the stack.
+const MAX_ANY_VALUE_DEPTH = 32
+
+var errAnyValueTooDeep = fmt.Errorf("AnyValue nested more than %d levels deep", MAX_ANY_VALUE_DEPTH)
+
+// Decodes a protobuf encoded ExportLogsServiceRequest
+func DecodeLogsProto(b []byte) (LogsRequest, error) {
+	var req LogsRequest
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		msg, err := f.Message()
+		if err != nil {
+			return err
+		}
+		resource, err := decodeResourceLogs(msg)
+		if err != nil {
+			return err
+		}
+		req.ResourceLogs = append(req.ResourceLogs, resource)
+		return nil
+	})
+	return req, err
+}
+
+func decodeResourceLogs(b []byte) (ResourceLogs, error) {
+	resource := ResourceLogs{Attributes: map[string]any{}}
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		switch f.Num {
+		case 1:
+			msg, err := f.Message()
+			if err != nil {
+				return err
+			}
+			return pbwire.EachField(msg, func(f pbwire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				return decodeKeyValueInto(f, resource.Attributes, 0)
+			})
+		case 2:
+			msg, err := f.Message()
+			if err != nil {
+				return err
+			}
+			scope, err := decodeScopeLogs(msg)
+			if err != nil {
+				return err
+			}
+			resource.ScopeLogs = append(resource.ScopeLogs, scope)
+		}
+		return nil
+	})
+	return resource, err
+}
+
+func decodeScopeLogs(b []byte) (ScopeLogs, error) {
+	var scope ScopeLogs
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		switch f.Num {
+		case 1:
+			msg, err := f.Message()
+			if err != nil {
+				return err
+			}
+			return pbwire.EachField(msg, func(f pbwire.Field) error {
+				var err error
+				switch f.Num {
+				case 1:
+					scope.ScopeName, err = f.String()
+				case 2:
+					scope.ScopeVersion, err = f.String()
+				}
+				return err
+			})
+		case 2:
+			msg, err := f.Message()
+			if err != nil {
+				return err
+			}
+			record, err := decodeLogRecord(msg)
+			if err != nil {
+				return err
+			}
+			scope.LogRecords = append(scope.LogRecords, record)
+		}
+		return nil
+	})
+	return scope, err
+}
+
+func decodeLogRecord(b []byte) (LogRecord, error) {
+	record := LogRecord{Attributes: map[string]any{}}
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			record.TimeUnixNano, err = f.Uint()
+		case 2:
+			var v int64
+			v, err = f.Int()
+			record.SeverityNumber = int32(v)
+		case 3:
+			record.SeverityText, err = f.String()
+		case 5:
+			var msg []byte
+			msg, err = f.Message()
+			if err != nil {
+				return err
+			}
+			record.Body, err = decodeAnyValue(msg, 0)
+		case 6:
+			err = decodeKeyValueInto(f, record.Attributes, 0)
+		case 8:
+			var v uint64
+			v, err = f.Uint()
+			record.Flags = uint32(v)
+		case 9:
+			var msg []byte
+			msg, err = f.Message()
+			record.TraceId = hex.EncodeToString(msg)
+		case 10:
+			var msg []byte
+			msg, err = f.Message()
+			record.SpanId = hex.EncodeToString(msg)
+		case 11:
+			record.ObservedTimeUnixNano, err = f.Uint()
+		case 12:
+			record.EventName, err = f.String()
+		}
+		return err
+	})
+	return record, err
+}
+
+func decodeKeyValueInto(f pbwire.Field, into map[string]any, depth int) error {
+	msg, err := f.Message()
+	if err != nil {
+		return err
+	}
+	var key string
+	var value any
+	err = pbwire.EachField(msg, func(f pbwire.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			key, err = f.String()
+		case 2:
+			var msg []byte
+			msg, err = f.Message()
+			if err != nil {
+				return err
+			}
+			value, err = decodeAnyValue(msg, depth)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	into[key] = value
+	return nil
+}
+
+// Decodes an AnyValue nested depth arrays and kvlists deep
+func decodeAnyValue(b []byte, depth int) (any, error) {
+	if depth > MAX_ANY_VALUE_DEPTH {
+		return nil, errAnyValueTooDeep
+	}
+	var value any
+	err := pbwire.EachField(b, func(f pbwire.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			value, err = f.String()
+		case 2:
+			value, err = f.Bool()
+		case 3:
+			value, err = f.Int()
+		case 4:
+			value, err = f.Double()
+		case 5:
+			var msg []byte
+			msg, err = f.Message()
+			if err != nil {
+				return err
+			}
+			values := []any{}
+			err = pbwire.EachField(msg, func(f pbwire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				msg, err := f.Message()
+				if err != nil {
+					return err
+				}
+				v, err := decodeAnyValue(msg, depth+1)
+				values = append(values, v)
+				return err
+			})
+			value = values
+		case 6:
+			var msg []byte
+			msg, err = f.Message()
+			if err != nil {
+				return err
+			}
+			values := map[string]any{}
+			err = pbwire.EachField(msg, func(f pbwire.Field) error {
+				if f.Num != 1 {
+					return nil
+				}
+				return decodeKeyValueInto(f, values, depth+1)
+			})
+			value = values
+		case 7:
+			value, err = f.Message()
+		}
+		return err
+	})
+	return value, err
+}
+
+// Encodes an ExportLogsServiceResponse, only populating partial_success when records were rejected
+func EncodeLogsResponseProto(rejected int64, message string) []byte {
+	if rejected == 0 && message == "" {
+		return []byte{}
+	}
+	var partial []byte
+	partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(rejected))
+	partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+	partial = protowire.AppendString(partial, message)
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, partial)
+	return b
+}
@@ -0,0 +1,112 @@
+package otlp
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// An AnyValue string inside depth array_values, alternating with kvlist_values when kvlist is set
+func nestedAnyValue(depth int, kvlist bool) []byte {
+	var value []byte
+	value = protowire.AppendTag(value, 1, protowire.BytesType)
+	value = protowire.AppendString(value, "leaf")
+	for i := 0; i < depth; i++ {
+		if kvlist && i%2 == 1 {
+			var kv []byte
+			kv = protowire.AppendTag(kv, 1, protowire.BytesType)
+			kv = protowire.AppendString(kv, "key")
+			kv = appendMessage(kv, 2, value)
+			value = appendMessage(nil, 6, appendMessage(nil, 1, kv))
+			continue
+		}
+		value = appendMessage(nil, 5, appendMessage(nil, 1, value))
+	}
+	return value
+}
+
+// An ExportLogsServiceRequest with one record whose body is body
+func logsRequestWithBody(body []byte) []byte {
+	record := appendMessage(nil, 5, body)
+	scope := appendMessage(nil, 2, record)
+	resource := appendMessage(nil, 2, scope)
+	return appendMessage(nil, 1, resource)
+}
+
+func TestDecodeLogsProtoNesting(t *testing.T) {
+	tests := []struct {
+		name    string
+		depth   int
+		kvlist  bool
+		wantErr bool
+	}{
+		{"flat", 0, false, false},
+		{"arrays at the limit", MAX_ANY_VALUE_DEPTH, false, false},
+		{"kvlists at the limit", MAX_ANY_VALUE_DEPTH, true, false},
+		{"arrays past the limit", MAX_ANY_VALUE_DEPTH + 1, false, true},
+		{"kvlists past the limit", MAX_ANY_VALUE_DEPTH + 1, true, true},
+		// Deep enough to have overflowed the stack when the encoded body was a few megabytes
+		{"deeply nested", 5000, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := DecodeLogsProto(logsRequestWithBody(nestedAnyValue(test.depth, test.kvlist)))
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Count() != 1 {
+				t.Fatalf("expected 1 record, got %d", req.Count())
+			}
+		})
+	}
+}
+
+func TestDecodeLogsProtoAttributeNesting(t *testing.T) {
+	var kv []byte
+	kv = protowire.AppendTag(kv, 1, protowire.BytesType)
+	kv = protowire.AppendString(kv, "deep")
+	kv = appendMessage(kv, 2, nestedAnyValue(MAX_ANY_VALUE_DEPTH+1, false))
+	record := appendMessage(nil, 6, kv)
+	b := appendMessage(nil, 1, appendMessage(nil, 2, appendMessage(nil, 2, record)))
+	_, err := DecodeLogsProto(b)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func nestedJsonAnyValue(depth int) string {
+	return strings.Repeat(`{"arrayValue":{"values":[`, depth) + `{"stringValue":"leaf"}` + strings.Repeat(`]}}`, depth)
+}
+
+func TestDecodeLogsJsonNesting(t *testing.T) {
+	tests := []struct {
+		name    string
+		depth   int
+		wantErr bool
+	}{
+		{"flat", 0, false},
+		{"at the limit", MAX_ANY_VALUE_DEPTH, false},
+		{"past the limit", MAX_ANY_VALUE_DEPTH + 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"body":` + nestedJsonAnyValue(test.depth) + `}]}]}]}`
+			_, err := DecodeLogsJson([]byte(body))
+			if test.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package pbwire
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A single decoded field of a protobuf message.
+// Only the handful of messages the ingestion endpoints accept are decoded, so they are walked field by field
+// rather than generated from their .proto files.
+type Field struct {
+	Num protowire.Number
+	Typ protowire.Type
+	// Set for varint, fixed32 and fixed64 fields
+	Value uint64
+	// Set for length delimited fields: strings, bytes and embedded messages
+	Bytes []byte
+}
+
+var ErrWireType = errors.New("unexpected protobuf wire type")
+
+// Calls fn with every field of the encoded message, in the order they were written
+func EachField(b []byte, fn func(f Field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := Field{Num: num, Typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.Value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Value = uint64(v)
+		case protowire.Fixed64Type:
+			f.Value, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		err := fn(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Field) String() (string, error) {
+	if f.Typ != protowire.BytesType {
+		return "", ErrWireType
+	}
+	return string(f.Bytes), nil
+}
+
+func (f Field) Message() ([]byte, error) {
+	if f.Typ != protowire.BytesType {
+		return nil, ErrWireType
+	}
+	return f.Bytes, nil
+}
+
+func (f Field) Uint() (uint64, error) {
+	if f.Typ == protowire.BytesType {
+		return 0, ErrWireType
+	}
+	return f.Value, nil
+}
+
+func (f Field) Int() (int64, error) {
+	v, err := f.Uint()
+	return int64(v), err
+}
+
+func (f Field) Bool() (bool, error) {
+	v, err := f.Uint()
+	return v != 0, err
+}
+
+func (f Field) Double() (float64, error) {
+	if f.Typ != protowire.Fixed64Type {
+		return 0, ErrWireType
+	}
+	return math.Float64frombits(f.Value), nil
+}
@@ -0,0 +1,257 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Longest search accepted, tsqueries grow with every term
+const MAX_QUERY_LENGTH = 1024
+
+// A search that couldn't be parsed, with the byte offset of the problem in the original text
+type SyntaxError struct {
+	Message  string
+	Position int
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+type tokenKind int
+
+const (
+	TOKEN_TERM tokenKind = iota
+	TOKEN_PHRASE
+	TOKEN_AND
+	TOKEN_OR
+	TOKEN_NOT
+	TOKEN_OPEN
+	TOKEN_CLOSE
+	TOKEN_END
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	prefix   bool
+	position int
+}
+
+// Compiles a search into the text of a postgres tsquery, for to_tsquery.
+// Terms are ANDed unless joined by OR, eg `"connection reset" OR timeout -retry conn*`:
+//   - "quoted words" match as a phrase
+//   - OR, AND and parentheses group terms, AND binds tighter than OR
+//   - NOT or a leading - excludes a term
+//   - a trailing * matches words starting with the term
+//
+// Every term is quoted in the output, so nothing in the search is interpreted by to_tsquery itself.
+func ToTsquery(q string) (string, error) {
+	if len(q) > MAX_QUERY_LENGTH {
+		return "", SyntaxError{Message: "search is too long", Position: MAX_QUERY_LENGTH}
+	}
+	tokens, err := tokenize(q)
+	if err != nil {
+		return "", err
+	}
+	p := parser{tokens: tokens}
+	if p.peek().kind == TOKEN_END {
+		return "", SyntaxError{Message: "search is empty", Position: 0}
+	}
+	out, err := p.or()
+	if err != nil {
+		return "", err
+	}
+	if next := p.peek(); next.kind != TOKEN_END {
+		return "", SyntaxError{Message: "unexpected " + describe(next), Position: next.position}
+	}
+	return out, nil
+}
+
+func tokenize(q string) ([]token, error) {
+	tokens := []token{}
+	i := 0
+	for i < len(q) {
+		c := q[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: TOKEN_OPEN, position: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: TOKEN_CLOSE, position: i})
+			i++
+		case c == '-':
+			tokens = append(tokens, token{kind: TOKEN_NOT, position: i})
+			i++
+		case c == '"':
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, SyntaxError{Message: "unterminated phrase", Position: i}
+			}
+			tokens = append(tokens, token{kind: TOKEN_PHRASE, text: q[i+1 : i+1+end], position: i})
+			i += end + 2
+		default:
+			start := i
+			for i < len(q) && !isSpace(q[i]) && q[i] != '(' && q[i] != ')' && q[i] != '"' {
+				i++
+			}
+			word := q[start:i]
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: TOKEN_AND, position: start})
+			case "OR":
+				tokens = append(tokens, token{kind: TOKEN_OR, position: start})
+			case "NOT":
+				tokens = append(tokens, token{kind: TOKEN_NOT, position: start})
+			default:
+				prefix := strings.HasSuffix(word, "*")
+				word = strings.TrimRight(word, "*")
+				if word == "" {
+					return nil, SyntaxError{Message: "prefix needs at least one character", Position: start}
+				}
+				tokens = append(tokens, token{kind: TOKEN_TERM, text: word, prefix: prefix, position: start})
+			}
+		}
+	}
+	return append(tokens, token{kind: TOKEN_END, position: len(q)}), nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != TOKEN_END {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) or() (string, error) {
+	left, err := p.and()
+	if err != nil {
+		return "", err
+	}
+	terms := []string{left}
+	for p.peek().kind == TOKEN_OR {
+		p.take()
+		right, err := p.and()
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, right)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return "(" + strings.Join(terms, " | ") + ")", nil
+}
+
+// Terms next to each other are ANDed, as are terms joined by an explicit AND
+func (p *parser) and() (string, error) {
+	left, err := p.unary()
+	if err != nil {
+		return "", err
+	}
+	terms := []string{left}
+	for {
+		next := p.peek()
+		if next.kind == TOKEN_AND {
+			p.take()
+		} else if next.kind == TOKEN_OR || next.kind == TOKEN_CLOSE || next.kind == TOKEN_END {
+			break
+		}
+		right, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, right)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return "(" + strings.Join(terms, " & ") + ")", nil
+}
+
+func (p *parser) unary() (string, error) {
+	if p.peek().kind == TOKEN_NOT {
+		p.take()
+		operand, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		return "!" + operand, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (string, error) {
+	t := p.take()
+	switch t.kind {
+	case TOKEN_TERM:
+		term := quote(t.text)
+		if t.prefix {
+			term += ":*"
+		}
+		return term, nil
+	case TOKEN_PHRASE:
+		words := strings.Fields(t.text)
+		if len(words) == 0 {
+			return "", SyntaxError{Message: "phrase is empty", Position: t.position}
+		}
+		for i, word := range words {
+			words[i] = quote(word)
+		}
+		if len(words) == 1 {
+			return words[0], nil
+		}
+		return "(" + strings.Join(words, " <-> ") + ")", nil
+	case TOKEN_OPEN:
+		inner, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		if closing := p.take(); closing.kind != TOKEN_CLOSE {
+			return "", SyntaxError{Message: "unclosed parenthesis", Position: t.position}
+		}
+		return inner, nil
+	}
+	return "", SyntaxError{Message: "expected a term but found " + describe(t), Position: t.position}
+}
+
+// Quotes a term as a tsquery literal, which to_tsquery then normalises with the text search configuration
+func quote(term string) string {
+	term = strings.ReplaceAll(term, `\`, `\\`)
+	return "'" + strings.ReplaceAll(term, "'", "''") + "'"
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case TOKEN_AND:
+		return "AND"
+	case TOKEN_OR:
+		return "OR"
+	case TOKEN_NOT:
+		return "NOT"
+	case TOKEN_OPEN:
+		return "("
+	case TOKEN_CLOSE:
+		return ")"
+	case TOKEN_END:
+		return "end of search"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// Searches are split on ascii whitespace only, so bytes of multibyte characters are never mistaken for spaces
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
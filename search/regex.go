@@ -0,0 +1,106 @@
+package search
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+)
+
+// Limits on regex searches, which run against every message that passes the trigram index
+const (
+	MAX_REGEX_LENGTH = 256
+	// pg_trgm can only narrow a regex down with literals of at least a trigram, without one every message is scanned
+	MIN_REGEX_LITERAL = 3
+	// Counted repetition such as a{1000} is expanded by postgres' regex compiler
+	MAX_REGEX_REPEAT = 100
+	// Quantifiers inside quantifiers, eg (a+)+, blow up the states postgres has to track
+	MAX_REGEX_NESTED_REPEATS = 2
+)
+
+// Substring searches shorter than a trigram can't use the index
+const MIN_SUBSTRING_LENGTH = 3
+
+// Rejects regexes that aren't valid or would be too expensive to run on every log.
+// Patterns are checked with RE2 syntax, which postgres' regexes are a superset of, so backreferences and lookarounds are refused.
+func CheckRegex(pattern string) error {
+	if len(pattern) > MAX_REGEX_LENGTH {
+		return SyntaxError{Message: "regex is too long", Position: MAX_REGEX_LENGTH}
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		var parseErr *syntax.Error
+		if errors.As(err, &parseErr) {
+			return SyntaxError{Message: "invalid regex, " + parseErr.Code.String(), Position: max(strings.Index(pattern, parseErr.Expr), 0)}
+		}
+		return SyntaxError{Message: "invalid regex", Position: 0}
+	}
+	err = checkRepeats(re, 0)
+	if err != nil {
+		return err
+	}
+	if requiredLiteral(re) < MIN_REGEX_LITERAL {
+		return SyntaxError{Message: "regex needs a literal of at least 3 characters that every match contains", Position: 0}
+	}
+	return nil
+}
+
+func checkRepeats(re *syntax.Regexp, depth int) error {
+	switch re.Op {
+	case syntax.OpRepeat:
+		if re.Min > MAX_REGEX_REPEAT || re.Max > MAX_REGEX_REPEAT {
+			return SyntaxError{Message: "regex repeat count is too large", Position: 0}
+		}
+		depth++
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		depth++
+	}
+	if depth > MAX_REGEX_NESTED_REPEATS {
+		return SyntaxError{Message: "regex has too many nested quantifiers", Position: 0}
+	}
+	for _, sub := range re.Sub {
+		err := checkRepeats(sub, depth)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Length of the longest run of literal characters every match of re must contain
+func requiredLiteral(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune)
+	case syntax.OpCapture:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiteral(re.Sub[0])
+		}
+	case syntax.OpAlternate:
+		shortest := -1
+		for _, sub := range re.Sub {
+			n := requiredLiteral(sub)
+			if shortest < 0 || n < shortest {
+				shortest = n
+			}
+		}
+		return max(shortest, 0)
+	case syntax.OpConcat:
+		longest := 0
+		run := 0
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run += len(sub.Rune)
+				longest = max(longest, run)
+				continue
+			}
+			run = 0
+			longest = max(longest, requiredLiteral(sub))
+		}
+		return longest
+	}
+	return 0
+}
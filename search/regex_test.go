@@ -0,0 +1,49 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRegex(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    *SyntaxError
+	}{
+		{"literal", "timeout", nil},
+		{"literal around a class", `user \d+ logged in`, nil},
+		{"case insensitive", "(?i)timeout", nil},
+		{"every alternative has a literal", "(timeout|refused) after", nil},
+		{"literal inside a plus", "(retry)+", nil},
+		{"repeat at the limit", "err(or){100}", nil},
+		{"two nested quantifiers", `(abc\d+)+`, nil},
+		{"too long", strings.Repeat("a", MAX_REGEX_LENGTH+1), &SyntaxError{Message: "regex is too long", Position: MAX_REGEX_LENGTH}},
+		{"missing paren", "time(out", &SyntaxError{Message: "invalid regex, missing closing )", Position: 0}},
+		{"bad repeat", "abc**", &SyntaxError{Message: "invalid regex, invalid nested repetition operator", Position: 3}},
+		{"backreference", `(abc)\1`, &SyntaxError{Message: "invalid regex, invalid escape sequence", Position: 5}},
+		{"lookahead", "abc(?=def)", &SyntaxError{Message: "invalid regex, invalid or unsupported Perl syntax", Position: 3}},
+		{"repeat too large", "abc{101}", &SyntaxError{Message: "regex repeat count is too large", Position: 0}},
+		{"too many nested quantifiers", "((abc+)+)+", &SyntaxError{Message: "regex has too many nested quantifiers", Position: 0}},
+		{"no literal", `\d+`, &SyntaxError{Message: "regex needs a literal of at least 3 characters that every match contains", Position: 0}},
+		{"literal too short", "ab.*", &SyntaxError{Message: "regex needs a literal of at least 3 characters that every match contains", Position: 0}},
+		{"an alternative without a literal", "timeout|.*", &SyntaxError{Message: "regex needs a literal of at least 3 characters that every match contains", Position: 0}},
+		{"optional literal", "(timeout)?", &SyntaxError{Message: "regex needs a literal of at least 3 characters that every match contains", Position: 0}},
+		{"literal under a star", "(timeout)*", &SyntaxError{Message: "regex needs a literal of at least 3 characters that every match contains", Position: 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckRegex(test.pattern)
+			if test.want == nil {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+				return
+			}
+			got, ok := err.(SyntaxError)
+			if !ok || got != *test.want {
+				t.Errorf("got %v, want %v", err, *test.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 \c everylog;
 -- Insert into log_level
-INSERT INTO log_level (id, value)
+-- Severity orders levels, ids are kept as they were before severities existed
+INSERT INTO log_level (id, value, severity)
 VALUES
-  (100, 'INFO'),
-  (200, 'DEBUG'),
-  (300, 'WARNING'),
-  (400, 'ERROR'),
-  (500, 'CRITICAL');
+  (100, 'INFO', 200),
+  (200, 'DEBUG', 100),
+  (300, 'WARNING', 300),
+  (400, 'ERROR', 400),
+  (500, 'CRITICAL', 500);
@@ -1,6 +1,9 @@
 CREATE DATABASE everylog;
 \c everylog
 
+-- Trigram indexes for fuzzy, substring and regex searches of log messages
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 -- Create table for locations first as it's referenced in other tables
 CREATE TABLE IF NOT EXISTS location (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
@@ -101,6 +104,10 @@ CREATE TABLE IF NOT EXISTS permitted_project (
     FOREIGN KEY (project_id) REFERENCES project(id)
 );
 
+-- Reads are limited to the projects a user is permitted on or that are shared with their orgs
+CREATE INDEX IF NOT EXISTS permitted_project_user_id_idx ON permitted_project (user_id, project_id);
+CREATE INDEX IF NOT EXISTS project_org_org_id_idx ON project_org (org_id, project_id);
+
 -- Create table for invites
 CREATE TABLE IF NOT EXISTS project_invite (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
@@ -143,16 +150,62 @@ CREATE TABLE IF NOT EXISTS process (
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     project_id UUID,
     name VARCHAR(255),
+    last_seen_at TIMESTAMPTZ,
     FOREIGN KEY (project_id) REFERENCES project(id),
     CONSTRAINT process_unique UNIQUE (name, project_id)
 );
 
+-- Counts a project's processes against db.MAX_PROCESSES_PER_PROJECT
+CREATE INDEX IF NOT EXISTS process_project_id_idx ON process (project_id);
+
 -- Create table for log levels
+-- Built in levels have no project and fixed ids below 1000, projects' own levels are numbered from the sequence
+CREATE SEQUENCE IF NOT EXISTS log_level_id_seq START 1000;
 CREATE TABLE IF NOT EXISTS log_level (
-    id INT PRIMARY KEY NOT NULL,
-    value VARCHAR(255)
+    id INT PRIMARY KEY DEFAULT nextval('log_level_id_seq') NOT NULL,
+    project_id UUID,
+    value VARCHAR(255),
+    severity INT DEFAULT 0 NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES project(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS log_level_project_value_idx ON log_level (project_id, upper(value));
+
+-- Create table for issues, the groups of ERROR and more severe logs sharing a fingerprint
+CREATE TABLE IF NOT EXISTS issue (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    project_id UUID NOT NULL,
+    -- sha256 of the message template and top in app frames, see issues.New
+    fingerprint VARCHAR(64) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    exception TEXT,
+    culprit TEXT,
+    level_id INT NOT NULL,
+    -- unresolved, resolved or ignored
+    status VARCHAR(16) DEFAULT 'unresolved' NOT NULL,
+    count BIGINT DEFAULT 0 NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    -- Set when an occurrence after the issue was resolved reopened it
+    regressed_at TIMESTAMPTZ,
+    FOREIGN KEY (project_id) REFERENCES project(id),
+    FOREIGN KEY (level_id) REFERENCES log_level(id),
+    CONSTRAINT issue_unique UNIQUE (project_id, fingerprint)
 );
 
+CREATE INDEX IF NOT EXISTS issue_project_last_seen_at_idx ON issue (project_id, last_seen_at DESC);
+
+-- Create table for the processes an issue has occurred in
+CREATE TABLE IF NOT EXISTS issue_process (
+    issue_id UUID NOT NULL,
+    process_id UUID NOT NULL,
+    count BIGINT DEFAULT 0 NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (issue_id, process_id),
+    FOREIGN KEY (issue_id) REFERENCES issue(id),
+    FOREIGN KEY (process_id) REFERENCES process(id)
+);
 
 -- Create table for logs
 CREATE TABLE IF NOT EXISTS log (
@@ -164,9 +217,79 @@ CREATE TABLE IF NOT EXISTS log (
     process_id UUID,
     message TEXT,
     traceback TEXT,
+    attributes JSONB,
+    -- created_at is the event time, which clients may set, received_at is always the server's
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    skewed BOOLEAN DEFAULT FALSE NOT NULL,
+    -- W3C trace context ids, hex encoded
+    trace_id VARCHAR(32),
+    span_id VARCHAR(16),
+    parent_span_id VARCHAR(16),
+    -- Stacks parsed from traceback, see traceback.Stack
+    traceback_frames JSONB,
+    issue_id UUID,
+    -- Id of the transaction that wrote the log. Live tails resume from it, see db.streamHorizon.
+    commit_seq BIGINT DEFAULT pg_current_xact_id()::text::bigint,
+    -- Full text search over message and traceback, message matches rank higher. Must use db.SEARCH_CONFIG.
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(message, '')), 'A') || setweight(to_tsvector('english', coalesce(traceback, '')), 'B')
+    ) STORED,
     FOREIGN KEY (user_id) REFERENCES single_user(id),
     FOREIGN KEY (project_id) REFERENCES project(id),
     FOREIGN KEY (level_id) REFERENCES log_level(id),
-    FOREIGN KEY (process_id) REFERENCES process(id)
+    FOREIGN KEY (process_id) REFERENCES process(id),
+    FOREIGN KEY (issue_id) REFERENCES issue(id)
+);
+
+CREATE INDEX IF NOT EXISTS log_attributes_idx ON log USING GIN (attributes);
+CREATE INDEX IF NOT EXISTS log_project_created_at_idx ON log (project_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS log_process_created_at_idx ON log (process_id, created_at, id) WHERE process_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS log_commit_seq_idx ON log (commit_seq, id) WHERE commit_seq IS NOT NULL;
+CREATE INDEX IF NOT EXISTS log_trace_id_idx ON log (trace_id, created_at) WHERE trace_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS log_span_id_idx ON log (span_id) WHERE span_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS log_parent_span_id_idx ON log (parent_span_id) WHERE parent_span_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS log_search_vector_idx ON log USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS log_message_trgm_idx ON log USING GIN (message gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS log_issue_id_idx ON log (issue_id, created_at DESC) WHERE issue_id IS NOT NULL;
+
+-- Idempotency keys of logs written within IDEMPOTENCY_WINDOW, see db.reserveIdempotencyKeys.
+-- A key is reserved before its log is queued and only reported as a duplicate once committed is set by the transaction writing the log.
+CREATE TABLE IF NOT EXISTS log_idempotency (
+    project_id UUID NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    log_id UUID NOT NULL,
+    committed BOOLEAN DEFAULT FALSE NOT NULL,
+    reserved_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (project_id, idempotency_key),
+    FOREIGN KEY (project_id) REFERENCES project(id)
+);
+
+CREATE INDEX IF NOT EXISTS log_idempotency_reserved_at_idx ON log_idempotency (project_id, reserved_at);
+
+-- Create table for saved searches, named log views that are private to their owner or shared with a project or org
+CREATE TABLE IF NOT EXISTS saved_search (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    user_id UUID NOT NULL,
+    -- At most one is set, to share the search with everyone who can read the project or is in the org
+    project_id UUID,
+    org_id UUID,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    -- db.SearchFilters, the GET /log filters besides query, search and time_range
+    filters JSONB DEFAULT '{}' NOT NULL,
+    query TEXT DEFAULT '' NOT NULL,
+    search TEXT DEFAULT '' NOT NULL,
+    -- Resolved each time the search runs, see logquery.ParseRange. Empty searches all time.
+    time_range VARCHAR(255) DEFAULT '' NOT NULL,
+    columns TEXT[] DEFAULT '{}' NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES single_user(id),
+    FOREIGN KEY (project_id) REFERENCES project(id),
+    FOREIGN KEY (org_id) REFERENCES org(id),
+    CONSTRAINT saved_search_shared_once CHECK (project_id IS NULL OR org_id IS NULL)
 );
 
+CREATE INDEX IF NOT EXISTS saved_search_user_id_idx ON saved_search (user_id);
+CREATE INDEX IF NOT EXISTS saved_search_project_id_idx ON saved_search (project_id) WHERE project_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS saved_search_org_id_idx ON saved_search (org_id) WHERE org_id IS NOT NULL;
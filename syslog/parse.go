@@ -0,0 +1,204 @@
+package syslog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severities as defined by RFC 5424 section 6.2.1
+const (
+	SEVERITY_EMERGENCY = 0
+	SEVERITY_ALERT     = 1
+	SEVERITY_CRITICAL  = 2
+	SEVERITY_ERROR     = 3
+	SEVERITY_WARNING   = 4
+	SEVERITY_NOTICE    = 5
+	SEVERITY_INFO      = 6
+	SEVERITY_DEBUG     = 7
+)
+
+// Used when a message has no PRI, as RFC 3164 section 4.3.3 asks relays to assume (user.notice)
+const DEFAULT_PRIORITY = 13
+
+// A parsed syslog message, fields the sender left out (or sent as NILVALUE) are empty
+type Message struct {
+	Facility  int
+	Severity  int
+	Timestamp *time.Time
+	Hostname  string
+	AppName   string
+	ProcId    string
+	MsgId     string
+	// SD-ID to its params, only present in RFC 5424 messages
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+var ErrEmpty = errors.New("empty syslog message")
+var ErrPriority = errors.New("invalid syslog priority")
+var ErrStructuredData = errors.New("invalid syslog structured data")
+
+// Parses an RFC 5424 message, falling back to the legacy BSD format of RFC 3164
+func Parse(b []byte, now time.Time) (Message, error) {
+	s := strings.TrimRight(string(b), "\r\n\x00")
+	if strings.TrimSpace(s) == "" {
+		return Message{}, ErrEmpty
+	}
+	priority, rest, err := parsePriority(s)
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{Facility: priority / 8, Severity: priority % 8}
+	if strings.HasPrefix(rest, "1 ") {
+		err = parse5424(rest[2:], &msg)
+		return msg, err
+	}
+	parse3164(rest, now, &msg)
+	return msg, nil
+}
+
+func parsePriority(s string) (int, string, error) {
+	if !strings.HasPrefix(s, "<") {
+		return DEFAULT_PRIORITY, s, nil
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return 0, "", ErrPriority
+	}
+	priority, err := strconv.Atoi(s[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, "", ErrPriority
+	}
+	return priority, s[end+1:], nil
+}
+
+// Splits off the next space separated header field, returning "" for the NILVALUE
+func nextField(s string) (string, string) {
+	field, rest, _ := strings.Cut(s, " ")
+	if field == "-" {
+		field = ""
+	}
+	return field, rest
+}
+
+func parse5424(s string, msg *Message) error {
+	var timestamp string
+	timestamp, s = nextField(s)
+	if timestamp != "" {
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err == nil {
+			msg.Timestamp = &t
+		}
+	}
+	msg.Hostname, s = nextField(s)
+	msg.AppName, s = nextField(s)
+	msg.ProcId, s = nextField(s)
+	msg.MsgId, s = nextField(s)
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	} else if strings.HasPrefix(s, "[") {
+		var err error
+		msg.StructuredData, s, err = parseStructuredData(s)
+		if err != nil {
+			return err
+		}
+	}
+	s = strings.TrimPrefix(s, " ")
+	msg.Message = strings.TrimPrefix(s, "\ufeff")
+	return nil
+}
+
+// Parses consecutive SD-ELEMENTs such as [exampleSDID@32473 iut="3" eventSource="Application"]
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+	data := map[string]map[string]string{}
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end <= 0 {
+			return nil, "", ErrStructuredData
+		}
+		id := s[:end]
+		params := map[string]string{}
+		s = s[end:]
+		for strings.HasPrefix(s, " ") {
+			s = strings.TrimLeft(s, " ")
+			eq := strings.Index(s, `="`)
+			if eq <= 0 {
+				return nil, "", ErrStructuredData
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(s); i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					value.WriteByte(s[i+1])
+					i++
+					continue
+				}
+				if s[i] == '"' {
+					s = s[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, "", ErrStructuredData
+			}
+			params[name] = value.String()
+		}
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", ErrStructuredData
+		}
+		s = s[1:]
+		data[id] = params
+	}
+	return data, s, nil
+}
+
+// Parses "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG". The format is loosely followed in practice,
+// so anything that doesn't fit is kept in the message rather than rejected.
+func parse3164(s string, now time.Time, msg *Message) {
+	if len(s) >= 15 {
+		t, err := time.ParseInLocation(time.Stamp, s[:15], now.Location())
+		if err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			// The format has no year, so a timestamp from late December arriving in January is last year's
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			msg.Timestamp = &t
+			s = strings.TrimPrefix(s[15:], " ")
+		}
+	}
+	if msg.Timestamp != nil {
+		// Local senders often omit the hostname and go straight to the tag
+		field, rest, _ := strings.Cut(s, " ")
+		if field != "" && !strings.ContainsAny(field, ":[") {
+			msg.Hostname = field
+			s = rest
+		}
+	}
+	end := strings.IndexAny(s, "[: ")
+	if end > 0 && end <= 48 {
+		msg.AppName = s[:end]
+		rest := s[end:]
+		if strings.HasPrefix(rest, "[") {
+			if bracket := strings.IndexByte(rest, ']'); bracket > 0 {
+				msg.ProcId = rest[1:bracket]
+				rest = rest[bracket+1:]
+			}
+		}
+		if strings.HasPrefix(rest, ":") {
+			s = strings.TrimPrefix(rest[1:], " ")
+		} else {
+			// Without the colon there's no way to tell a tag from the first word of the message
+			msg.AppName = ""
+			msg.ProcId = ""
+		}
+	}
+	msg.Message = s
+}
@@ -0,0 +1,114 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	at := func(t time.Time) *time.Time {
+		return &t
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  Message
+	}{
+		{
+			name:  "rfc 5424",
+			input: "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8",
+			want: Message{Facility: 4, Severity: SEVERITY_CRITICAL, Timestamp: at(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)),
+				Hostname: "mymachine.example.com", AppName: "su", MsgId: "ID47", Message: "'su root' failed for lonvick on /dev/pts/8"},
+		},
+		{
+			name:  "rfc 5424 structured data",
+			input: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"][examplePriority@32473 class="high"] An application event log entry`,
+			want: Message{Facility: 20, Severity: SEVERITY_NOTICE, Timestamp: at(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)),
+				Hostname: "mymachine.example.com", AppName: "evntslog", MsgId: "ID47",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473":     {"iut": "3", "eventSource": "Application"},
+					"examplePriority@32473": {"class": "high"},
+				},
+				Message: "An application event log entry"},
+		},
+		{
+			name:  "rfc 5424 escaped param values",
+			input: `<14>1 - - app 123 - [meta note="a \"quoted\" \] value"] done`,
+			want: Message{Facility: 1, Severity: SEVERITY_INFO, AppName: "app", ProcId: "123",
+				StructuredData: map[string]map[string]string{"meta": {"note": `a "quoted" ] value`}}, Message: "done"},
+		},
+		{
+			name:  "rfc 5424 byte order mark",
+			input: "<14>1 - host app - - - \ufeffhello",
+			want:  Message{Facility: 1, Severity: SEVERITY_INFO, Hostname: "host", AppName: "app", Message: "hello"},
+		},
+		{
+			name:  "rfc 3164 from last year",
+			input: "<13>Oct 11 22:14:15 mymachine su[230]: 'su root' failed",
+			want: Message{Facility: 1, Severity: SEVERITY_NOTICE, Timestamp: at(time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC)),
+				Hostname: "mymachine", AppName: "su", ProcId: "230", Message: "'su root' failed"},
+		},
+		{
+			name:  "rfc 3164 without a hostname",
+			input: "<78>May  7 09:00:00 cron: job started",
+			want: Message{Facility: 9, Severity: SEVERITY_INFO, Timestamp: at(time.Date(2024, 5, 7, 9, 0, 0, 0, time.UTC)),
+				AppName: "cron", Message: "job started"},
+		},
+		{
+			name:  "no priority or header",
+			input: "plain text message",
+			want:  Message{Facility: 1, Severity: SEVERITY_NOTICE, Message: "plain text message"},
+		},
+		{
+			name:  "trailing line ending and nul",
+			input: "<11>app: boom\r\n\x00",
+			want:  Message{Facility: 1, Severity: SEVERITY_ERROR, AppName: "app", Message: "boom"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse([]byte(test.input), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got.Timestamp == nil) != (test.want.Timestamp == nil) || got.Timestamp != nil && !got.Timestamp.Equal(*test.want.Timestamp) {
+				t.Errorf("got timestamp %v, want %v", got.Timestamp, test.want.Timestamp)
+			}
+			got.Timestamp = nil
+			test.want.Timestamp = nil
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{"empty", "", ErrEmpty},
+		{"only a line ending", "\r\n", ErrEmpty},
+		{"priority out of range", "<192>hello", ErrPriority},
+		{"empty priority", "<>hello", ErrPriority},
+		{"priority not a number", "<1a>hello", ErrPriority},
+		{"priority too long", "<12345>hello", ErrPriority},
+		{"unclosed priority", "<13 hello", ErrPriority},
+		{"unterminated param value", `<14>1 - - - - - [meta note="x`, ErrStructuredData},
+		{"unquoted param value", `<14>1 - - - - - [meta note=x] hello`, ErrStructuredData},
+		{"empty sd-id", `<14>1 - - - - - [ note="x"] hello`, ErrStructuredData},
+		{"unclosed element", `<14>1 - - - - - [meta note="x" hello`, ErrStructuredData},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.input), time.Now())
+			if err != test.want {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
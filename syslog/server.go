@@ -0,0 +1,398 @@
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jesses-code-adventures/every_log/db"
+	"github.com/joho/godotenv"
+)
+
+// Comma separated listener urls, each bound to the project its api key was issued for, eg
+// SYSLOG_LISTENERS="udp://:5514?user_id=...&project_id=...&api_key=...,tcp://:6514?user_id=...&project_id=...&api_key=..."
+const LISTENERS_ENV = "SYSLOG_LISTENERS"
+
+// Parsed messages are written once this many are pending or the interval has passed
+const FLUSH_SIZE = 500
+const FLUSH_INTERVAL = 200 * time.Millisecond
+
+// Messages waiting to be written per listener, beyond this they are dropped as a udp sender would expect
+const BUFFER_SIZE = 10000
+
+const MAX_MESSAGE_BYTES = 64 * 1024
+
+// Longest octet counting prefix read before a frame is rejected, enough for any length up to MAX_MESSAGE_BYTES
+const MAX_FRAME_LENGTH_DIGITS = 7
+
+type Listener struct {
+	Network   string
+	Address   string
+	UserId    string
+	ProjectId string
+	ApiKey    string
+}
+
+func (l Listener) String() string {
+	return fmt.Sprintf("%s://%s", l.Network, l.Address)
+}
+
+// Parses the listener urls described by LISTENERS_ENV
+func ParseListeners(spec string) ([]Listener, error) {
+	listeners := []Listener{}
+	for _, raw := range strings.Split(spec, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "udp" && u.Scheme != "tcp" {
+			return nil, fmt.Errorf("syslog listener %s: network must be udp or tcp", raw)
+		}
+		query := u.Query()
+		listener := Listener{
+			Network:   u.Scheme,
+			Address:   u.Host,
+			UserId:    query.Get("user_id"),
+			ProjectId: query.Get("project_id"),
+			ApiKey:    query.Get("api_key"),
+		}
+		if listener.UserId == "" || listener.ProjectId == "" || listener.ApiKey == "" {
+			return nil, fmt.Errorf("syslog listener %s: user_id, project_id and api_key are required", listener)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+func ListenersFromEnv() ([]Listener, error) {
+	godotenv.Load()
+	return ParseListeners(os.Getenv(LISTENERS_ENV))
+}
+
+// Runs the syslog listeners next to the http server, writing what they receive as logs
+type Server struct {
+	Db        *db.Db
+	Logger    *log.Logger
+	listeners []*listener
+}
+
+type listener struct {
+	config     Listener
+	db         *db.Db
+	logger     *log.Logger
+	messages   chan Message
+	packetConn net.PacketConn
+	streamLn   net.Listener
+	conns      map[net.Conn]bool
+	closed     bool
+	connsMu    sync.Mutex
+	readers    sync.WaitGroup
+	writer     sync.WaitGroup
+}
+
+func NewServer(database *db.Db, logger *log.Logger, listeners []Listener) *Server {
+	s := &Server{Db: database, Logger: logger}
+	for _, config := range listeners {
+		s.listeners = append(s.listeners, &listener{
+			config:   config,
+			db:       database,
+			logger:   logger,
+			messages: make(chan Message, BUFFER_SIZE),
+			conns:    map[net.Conn]bool{},
+		})
+	}
+	return s
+}
+
+// Checks each listener's api key belongs to its project, then starts listening
+func (s *Server) Start() error {
+	for _, l := range s.listeners {
+		projectId, err := s.Db.GetApiKeyProjectId(l.config.UserId, l.config.ApiKey)
+		if err != nil {
+			return fmt.Errorf("syslog listener %s: %w", l.config, err)
+		}
+		if projectId != l.config.ProjectId {
+			return fmt.Errorf("syslog listener %s: api key was not issued for project %s", l.config, l.config.ProjectId)
+		}
+		err = l.start()
+		if err != nil {
+			return fmt.Errorf("syslog listener %s: %w", l.config, err)
+		}
+		s.Logger.Printf("syslog listening on %s", l.config)
+	}
+	return nil
+}
+
+// Stops listening and writes any messages already received
+func (s *Server) Close() {
+	for _, l := range s.listeners {
+		l.close()
+	}
+}
+
+func (l *listener) start() error {
+	var err error
+	if l.config.Network == "udp" {
+		l.packetConn, err = net.ListenPacket("udp", l.config.Address)
+		if err != nil {
+			return err
+		}
+		l.readers.Add(1)
+		go l.readPackets()
+	} else {
+		l.streamLn, err = net.Listen("tcp", l.config.Address)
+		if err != nil {
+			return err
+		}
+		l.readers.Add(1)
+		go l.acceptStreams()
+	}
+	l.writer.Add(1)
+	go l.write()
+	return nil
+}
+
+func (l *listener) close() {
+	if l.packetConn != nil {
+		l.packetConn.Close()
+	}
+	if l.streamLn != nil {
+		l.streamLn.Close()
+		l.connsMu.Lock()
+		l.closed = true
+		for conn := range l.conns {
+			conn.Close()
+		}
+		l.connsMu.Unlock()
+	}
+	l.readers.Wait()
+	close(l.messages)
+	l.writer.Wait()
+}
+
+func (l *listener) receive(b []byte) {
+	msg, err := Parse(b, time.Now())
+	if err != nil {
+		if !errors.Is(err, ErrEmpty) {
+			l.logger.Printf("syslog listener %s: %s", l.config, err)
+		}
+		return
+	}
+	select {
+	case l.messages <- msg:
+	default:
+		l.logger.Printf("syslog listener %s: buffer full, dropped message", l.config)
+	}
+}
+
+// Each datagram holds a single message
+func (l *listener) readPackets() {
+	defer l.readers.Done()
+	buf := make([]byte, MAX_MESSAGE_BYTES)
+	for {
+		n, _, err := l.packetConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			l.logger.Printf("syslog listener %s: %s", l.config, err)
+			continue
+		}
+		l.receive(buf[:n])
+	}
+}
+
+func (l *listener) acceptStreams() {
+	defer l.readers.Done()
+	for {
+		conn, err := l.streamLn.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			l.logger.Printf("syslog listener %s: %s", l.config, err)
+			continue
+		}
+		l.connsMu.Lock()
+		if l.closed {
+			l.connsMu.Unlock()
+			conn.Close()
+			return
+		}
+		l.conns[conn] = true
+		l.connsMu.Unlock()
+		l.readers.Add(1)
+		go l.readStream(conn)
+	}
+}
+
+func (l *listener) readStream(conn net.Conn) {
+	defer l.readers.Done()
+	defer func() {
+		conn.Close()
+		l.connsMu.Lock()
+		delete(l.conns, conn)
+		l.connsMu.Unlock()
+	}()
+	reader := bufio.NewReaderSize(conn, MAX_MESSAGE_BYTES)
+	for {
+		frame, err := readFrame(reader)
+		if len(frame) > 0 {
+			l.receive(frame)
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				l.logger.Printf("syslog listener %s: %s", l.config, err)
+			}
+			return
+		}
+	}
+}
+
+// Reads one message from a stream, supporting both octet counting and newline delimited framing (RFC 6587)
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		length := make([]byte, 0, MAX_FRAME_LENGTH_DIGITS)
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if b == ' ' {
+				break
+			}
+			if b < '0' || b > '9' || len(length) == MAX_FRAME_LENGTH_DIGITS {
+				return nil, fmt.Errorf("invalid syslog frame length %q", append(length, b))
+			}
+			length = append(length, b)
+		}
+		n, err := strconv.Atoi(string(length))
+		if err != nil || n > MAX_MESSAGE_BYTES {
+			return nil, fmt.Errorf("invalid syslog frame length %q", length)
+		}
+		frame := make([]byte, n)
+		_, err = io.ReadFull(reader, frame)
+		if err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+	frame, err := reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("syslog message longer than %d bytes", MAX_MESSAGE_BYTES)
+	}
+	return append([]byte(nil), frame...), err
+}
+
+func (l *listener) write() {
+	defer l.writer.Done()
+	pending := make([]Message, 0, FLUSH_SIZE)
+	ticker := time.NewTicker(FLUSH_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-l.messages:
+			if !ok {
+				l.flush(pending)
+				return
+			}
+			pending = append(pending, msg)
+			if len(pending) >= FLUSH_SIZE {
+				l.flush(pending)
+				pending = pending[:0]
+			}
+		case <-ticker.C:
+			l.flush(pending)
+			pending = pending[:0]
+		}
+	}
+}
+
+func (l *listener) flush(messages []Message) {
+	if len(messages) == 0 {
+		return
+	}
+	entries := make([]db.LogEntry, len(messages))
+	for i, msg := range messages {
+		entries[i] = l.logEntry(msg)
+	}
+	results, err := l.db.WriteLogs(l.config.UserId, l.config.ProjectId, entries)
+	if err != nil {
+		l.logger.Printf("syslog listener %s: dropped %d messages: %s", l.config, len(entries), err)
+		return
+	}
+	rejected := 0
+	var firstErr string
+	for _, result := range results {
+		if result.Error != nil {
+			if rejected == 0 {
+				firstErr = *result.Error
+			}
+			rejected++
+		}
+	}
+	if rejected > 0 {
+		l.logger.Printf("syslog listener %s: rejected %d messages: %s", l.config, rejected, firstErr)
+	}
+}
+
+// Maps a message onto a log, with APP-NAME as the process and the remaining header fields as attributes
+func (l *listener) logEntry(msg Message) db.LogEntry {
+	entry := db.LogEntry{
+		ProjectId: l.config.ProjectId,
+		LevelId:   levelIdFromSeverity(msg.Severity),
+		Message:   msg.Message,
+		Timestamp: msg.Timestamp,
+		Attributes: map[string]any{
+			"syslog.facility": msg.Facility,
+			"syslog.severity": msg.Severity,
+		},
+	}
+	if msg.AppName != "" {
+		entry.Process = &msg.AppName
+	}
+	if msg.Hostname != "" {
+		entry.Attributes["syslog.hostname"] = msg.Hostname
+	}
+	if msg.ProcId != "" {
+		entry.Attributes["syslog.procid"] = msg.ProcId
+	}
+	if msg.MsgId != "" {
+		entry.Attributes["syslog.msgid"] = msg.MsgId
+	}
+	if len(msg.StructuredData) > 0 {
+		entry.Attributes["syslog.structured_data"] = msg.StructuredData
+	}
+	return entry
+}
+
+func levelIdFromSeverity(severity int) int {
+	switch {
+	case severity <= SEVERITY_CRITICAL:
+		return db.LEVEL_CRITICAL
+	case severity == SEVERITY_ERROR:
+		return db.LEVEL_ERROR
+	case severity == SEVERITY_WARNING:
+		return db.LEVEL_WARNING
+	case severity == SEVERITY_DEBUG:
+		return db.LEVEL_DEBUG
+	}
+	return db.LEVEL_INFO
+}
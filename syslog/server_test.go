@@ -0,0 +1,50 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"octet counted", "5 hello6 world!", []string{"hello", "world!"}, false},
+		{"newline delimited", "<13>first\n<13>second\n", []string{"<13>first\n", "<13>second\n"}, false},
+		{"length prefix too long", "12345678 hello", nil, true},
+		{"length past the max message", "65537 x", nil, true},
+		{"digits without a space", strings.Repeat("9", 1<<20), nil, true},
+		{"non digit in the length", "12a hello", nil, true},
+		{"truncated frame", "10 short", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := bufio.NewReaderSize(strings.NewReader(test.input), MAX_MESSAGE_BYTES)
+			got := []string{}
+			for {
+				frame, err := readFrame(reader)
+				if err == io.EOF && len(frame) == 0 {
+					break
+				}
+				if err != nil {
+					if !test.wantErr {
+						t.Fatal(err)
+					}
+					return
+				}
+				got = append(got, string(frame))
+			}
+			if test.wantErr {
+				t.Fatalf("expected an error, got frames %q", got)
+			}
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Fatalf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package traceback
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var goroutineHeader = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+
+// Location line under each function, eg "	/app/main.go:12 +0x1d"
+var goLocation = regexp.MustCompile(`^\t(.+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+
+// Parses panics, runtime/debug.Stack output and full goroutine dumps, one stack per goroutine.
+// Go prints the most recent call first, so frames are reversed.
+func parseGo(lines []string) []Stack {
+	var stacks []Stack
+	var exception, message string
+	var current *Stack
+	function := ""
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: "):
+			exception, message = splitException(line)
+			if strings.HasSuffix(message, " [recovered]") {
+				message = strings.TrimSuffix(message, " [recovered]")
+			}
+		case goroutineHeader.MatchString(line):
+			if current != nil {
+				stacks = append(stacks, *current)
+			}
+			current = &Stack{Language: LANGUAGE_GO, Thread: strings.TrimSuffix(line, ":")}
+			function = ""
+		case current == nil:
+		case goLocation.MatchString(line):
+			match := goLocation.FindStringSubmatch(line)
+			number, _ := strconv.Atoi(match[2])
+			current.Frames = append(current.Frames, Frame{
+				File:     match[1],
+				Line:     number,
+				Function: function,
+				InApp:    goInApp(match[1], function),
+			})
+			function = ""
+		case strings.TrimSpace(line) != "":
+			function = goFunction(strings.TrimSpace(line))
+		}
+	}
+	if current != nil {
+		stacks = append(stacks, *current)
+	}
+	for i := range stacks {
+		reverse(stacks[i].Frames)
+	}
+	// The panic belongs to the goroutine that raised it, which is printed first
+	if len(stacks) > 0 {
+		stacks[0].Exception = exception
+		stacks[0].Message = message
+	}
+	return stacks
+}
+
+// Strips the argument list from "net/http.(*conn).serve(0xc000, {0x1, 0x2})" and the
+// goroutine suffix from "created by net/http.(*Server).Serve in goroutine 1"
+func goFunction(line string) string {
+	line = strings.TrimPrefix(line, "created by ")
+	if i := strings.Index(line, " in goroutine "); i >= 0 {
+		line = line[:i]
+	}
+	if strings.HasSuffix(line, ")") {
+		depth := 0
+		for i := len(line) - 1; i >= 0; i-- {
+			switch line[i] {
+			case ')':
+				depth++
+			case '(':
+				depth--
+			}
+			if depth == 0 {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// Standard library packages have no dot in their first path element, dependencies live in the module cache or vendor
+func goInApp(file string, function string) bool {
+	if strings.Contains(file, "/go/pkg/mod/") || strings.Contains(file, "/vendor/") {
+		return false
+	}
+	if first, _, ok := strings.Cut(function, "/"); ok {
+		return strings.Contains(first, ".")
+	}
+	pkg, _, _ := strings.Cut(function, ".")
+	return pkg == "main" || pkg == ""
+}
@@ -0,0 +1,77 @@
+package traceback
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGo(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []Stack
+	}{
+		{
+			name: "panic",
+			trace: `panic: runtime error: index out of range [3] with length 3
+
+goroutine 1 [running]:
+main.lookup(...)
+	/app/main.go:12
+main.main()
+	/app/main.go:20 +0x1d
+exit status 2`,
+			want: []Stack{
+				{Language: LANGUAGE_GO, Exception: "panic", Message: "runtime error: index out of range [3] with length 3", Thread: "goroutine 1 [running]", Frames: []Frame{
+					{File: "/app/main.go", Line: 20, Function: "main.main", InApp: true},
+					{File: "/app/main.go", Line: 12, Function: "main.lookup", InApp: true},
+				}},
+			},
+		},
+		{
+			name: "recovered panic",
+			trace: `panic: order has no lines [recovered]
+
+goroutine 18 [running]:
+github.com/acme/shop/orders.(*Service).Checkout(0xc000010000, {0x7a1c20, 0xc0000b4000})
+	/app/orders/service.go:88 +0x65
+net/http.HandlerFunc.ServeHTTP(0xc0000a0000?, {0x7a1c20, 0xc0000b4000}, 0xc0000c2000)
+	/usr/local/go/src/net/http/server.go:2136 +0x29`,
+			want: []Stack{
+				{Language: LANGUAGE_GO, Exception: "panic", Message: "order has no lines", Thread: "goroutine 18 [running]", Frames: []Frame{
+					{File: "/usr/local/go/src/net/http/server.go", Line: 2136, Function: "net/http.HandlerFunc.ServeHTTP", InApp: false},
+					{File: "/app/orders/service.go", Line: 88, Function: "github.com/acme/shop/orders.(*Service).Checkout", InApp: true},
+				}},
+			},
+		},
+		{
+			name: "goroutine dump",
+			trace: `goroutine 1 [running]:
+main.main()
+	/app/main.go:20 +0x1d
+
+goroutine 7 [chan receive]:
+github.com/lib/pq.(*conn).recv(0xc000200000)
+	/root/go/pkg/mod/github.com/lib/pq@v1.10.9/conn.go:970 +0x45
+created by github.com/lib/pq.NewConnector in goroutine 1
+	/root/go/pkg/mod/github.com/lib/pq@v1.10.9/connector.go:40 +0x99`,
+			want: []Stack{
+				{Language: LANGUAGE_GO, Thread: "goroutine 1 [running]", Frames: []Frame{
+					{File: "/app/main.go", Line: 20, Function: "main.main", InApp: true},
+				}},
+				{Language: LANGUAGE_GO, Thread: "goroutine 7 [chan receive]", Frames: []Frame{
+					{File: "/root/go/pkg/mod/github.com/lib/pq@v1.10.9/connector.go", Line: 40, Function: "github.com/lib/pq.NewConnector", InApp: false},
+					{File: "/root/go/pkg/mod/github.com/lib/pq@v1.10.9/conn.go", Line: 970, Function: "github.com/lib/pq.(*conn).recv", InApp: false},
+				}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Parse(test.trace)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package traceback
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// eg "	at com.example.Foo.bar(Foo.java:42)", "	at java.base/java.lang.Thread.run(Thread.java:833)" or "	at Foo.bar(Native Method)"
+var javaFrame = regexp.MustCompile(`^\s*at ((?:[\w.$-]+/)*)([\w$.<>\[\]-]+)\(([^()]*)\)$`)
+
+// eg "java.lang.IllegalStateException: boom" or "Caused by: java.io.IOException"
+var javaException = regexp.MustCompile(`^\s*(?:Caused by: |Suppressed: |Exception in thread ".*" )?([a-zA-Z_$][\w$]*(?:\.[\w$]+)+)(?::\s*(.*))?$`)
+
+// Packages of the JDK and common frameworks, treated as not in app
+var javaLibraryPrefixes = []string{
+	"java.", "javax.", "jdk.", "sun.", "com.sun.", "kotlin.", "kotlinx.", "scala.",
+	"org.springframework.", "org.apache.", "org.junit.", "io.netty.", "org.hibernate.", "reactor.",
+}
+
+// Parses JVM stack traces, one stack per "Caused by" or "Suppressed" exception.
+// The JVM prints the most recent call first, so frames are reversed.
+func parseJava(lines []string) []Stack {
+	var stacks []Stack
+	var current *Stack
+	for _, line := range lines {
+		if match := javaFrame.FindStringSubmatch(line); match != nil {
+			if current == nil {
+				current = &Stack{Language: LANGUAGE_JAVA}
+			}
+			file, number := javaLocation(match[3])
+			current.Frames = append(current.Frames, Frame{
+				File:     file,
+				Line:     number,
+				Function: match[2],
+				InApp:    javaInApp(match[2]),
+			})
+			continue
+		}
+		if match := javaException.FindStringSubmatch(line); match != nil {
+			if current != nil {
+				stacks = append(stacks, *current)
+			}
+			current = &Stack{Language: LANGUAGE_JAVA, Exception: match[1], Message: strings.TrimSpace(match[2])}
+		}
+	}
+	if current != nil {
+		stacks = append(stacks, *current)
+	}
+	for i := range stacks {
+		reverse(stacks[i].Frames)
+	}
+	return stacks
+}
+
+// Splits "Foo.java:42", leaving both empty for "Native Method" and "Unknown Source"
+func javaLocation(location string) (string, int) {
+	file, line, ok := strings.Cut(location, ":")
+	if !ok {
+		if location == "Native Method" || location == "Unknown Source" {
+			return "", 0
+		}
+		return location, 0
+	}
+	number, _ := strconv.Atoi(line)
+	return file, number
+}
+
+func javaInApp(function string) bool {
+	for _, prefix := range javaLibraryPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return false
+		}
+	}
+	return true
+}
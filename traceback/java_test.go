@@ -0,0 +1,57 @@
+package traceback
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJava(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []Stack
+	}{
+		{
+			name: "caused by",
+			trace: `Exception in thread "main" java.lang.IllegalStateException: order 42 has no lines
+	at com.acme.shop.OrderService.checkout(OrderService.java:57)
+	at org.springframework.web.servlet.FrameworkServlet.service(FrameworkServlet.java:897)
+	at java.base/java.lang.Thread.run(Thread.java:833)
+Caused by: java.io.IOException: connection reset
+	at java.base/sun.nio.ch.SocketDispatcher.read0(Native Method)
+	at com.acme.shop.db.Pool.read(Pool.java:12)
+	... 3 more`,
+			want: []Stack{
+				{Language: LANGUAGE_JAVA, Exception: "java.lang.IllegalStateException", Message: "order 42 has no lines", Frames: []Frame{
+					{File: "Thread.java", Line: 833, Function: "java.lang.Thread.run", InApp: false},
+					{File: "FrameworkServlet.java", Line: 897, Function: "org.springframework.web.servlet.FrameworkServlet.service", InApp: false},
+					{File: "OrderService.java", Line: 57, Function: "com.acme.shop.OrderService.checkout", InApp: true},
+				}},
+				{Language: LANGUAGE_JAVA, Exception: "java.io.IOException", Message: "connection reset", Frames: []Frame{
+					{File: "Pool.java", Line: 12, Function: "com.acme.shop.db.Pool.read", InApp: true},
+					{File: "", Line: 0, Function: "sun.nio.ch.SocketDispatcher.read0", InApp: false},
+				}},
+			},
+		},
+		{
+			name: "kotlin without a message",
+			trace: `kotlin.UninitializedPropertyAccessException
+	at com.acme.app.MainActivity.onCreate(MainActivity.kt:21)
+	at com.acme.app.Loader.load(Unknown Source)`,
+			want: []Stack{
+				{Language: LANGUAGE_JAVA, Exception: "kotlin.UninitializedPropertyAccessException", Frames: []Frame{
+					{File: "", Line: 0, Function: "com.acme.app.Loader.load", InApp: true},
+					{File: "MainActivity.kt", Line: 21, Function: "com.acme.app.MainActivity.onCreate", InApp: true},
+				}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Parse(test.trace)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package traceback
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// eg "    at Object.<anonymous> (/app/index.js:10:5)", "    at /app/lib/x.js:3:9" or "    at async run (file:///app/x.mjs:1:2)"
+var nodeFrame = regexp.MustCompile(`^\s*at (?:(.+?) \()?(.+?):(\d+):\d+\)?$`)
+
+// Parses V8 stack traces as printed by node's Error.stack. Awaited calls are named without their "async " marker.
+// V8 prints the most recent call first, so frames are reversed.
+func parseNode(lines []string) []Stack {
+	var stacks []Stack
+	var current *Stack
+	for _, line := range lines {
+		if match := nodeFrame.FindStringSubmatch(line); match != nil {
+			if current == nil {
+				current = &Stack{Language: LANGUAGE_NODE}
+			}
+			number, _ := strconv.Atoi(match[3])
+			current.Frames = append(current.Frames, Frame{
+				File:     match[2],
+				Line:     number,
+				Function: strings.TrimPrefix(match[1], "async "),
+				InApp:    nodeInApp(match[2]),
+			})
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		// "at async Promise.all (index 0)" and similar have no location
+		if trimmed == "" || strings.HasPrefix(trimmed, "at ") {
+			continue
+		}
+		// Any other line starts a new error, eg the message of a "cause"
+		if current != nil && len(current.Frames) > 0 {
+			stacks = append(stacks, *current)
+			current = nil
+		}
+		if current == nil {
+			current = &Stack{Language: LANGUAGE_NODE}
+			current.Exception, current.Message = splitException(strings.TrimPrefix(trimmed, "[cause]: "))
+		}
+	}
+	if current != nil {
+		stacks = append(stacks, *current)
+	}
+	for i := range stacks {
+		reverse(stacks[i].Frames)
+	}
+	return stacks
+}
+
+func nodeInApp(file string) bool {
+	return !strings.Contains(file, "node_modules") && !strings.HasPrefix(file, "node:") && !strings.HasPrefix(file, "internal/")
+}
@@ -0,0 +1,57 @@
+package traceback
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNode(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []Stack
+	}{
+		{
+			name: "cause",
+			trace: `Error: payment failed
+    at chargeCard (/app/src/payments.js:31:11)
+    at async Promise.all (index 0)
+    at processTicksAndRejections (node:internal/process/task_queues:95:5)
+    at async checkout (/app/src/checkout.js:12:3)
+  [cause]: TypeError: Cannot read properties of undefined (reading 'id')
+      at lookupCustomer (/app/node_modules/stripe/lib/customers.js:88:20)
+      at /app/src/payments.js:25:9`,
+			want: []Stack{
+				{Language: LANGUAGE_NODE, Exception: "Error", Message: "payment failed", Frames: []Frame{
+					{File: "/app/src/checkout.js", Line: 12, Function: "checkout", InApp: true},
+					{File: "node:internal/process/task_queues", Line: 95, Function: "processTicksAndRejections", InApp: false},
+					{File: "/app/src/payments.js", Line: 31, Function: "chargeCard", InApp: true},
+				}},
+				{Language: LANGUAGE_NODE, Exception: "TypeError", Message: "Cannot read properties of undefined (reading 'id')", Frames: []Frame{
+					{File: "/app/src/payments.js", Line: 25, Function: "", InApp: true},
+					{File: "/app/node_modules/stripe/lib/customers.js", Line: 88, Function: "lookupCustomer", InApp: false},
+				}},
+			},
+		},
+		{
+			name: "es module",
+			trace: `RangeError: Maximum call stack size exceeded
+    at walk (file:///app/tree.mjs:4:3)
+    at Object.<anonymous> (file:///app/tree.mjs:9:1)`,
+			want: []Stack{
+				{Language: LANGUAGE_NODE, Exception: "RangeError", Message: "Maximum call stack size exceeded", Frames: []Frame{
+					{File: "file:///app/tree.mjs", Line: 9, Function: "Object.<anonymous>", InApp: true},
+					{File: "file:///app/tree.mjs", Line: 4, Function: "walk", InApp: true},
+				}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Parse(test.trace)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package traceback
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var pythonFrame = regexp.MustCompile(`^\s*File "(.+)", line (\d+)(?:, in (.+))?$`)
+
+// Lines separating the traces of chained exceptions
+var pythonChain = []string{
+	"During handling of the above exception, another exception occurred:",
+	"The above exception was the direct cause of the following exception:",
+}
+
+// Parses "Traceback (most recent call last):" blocks, which are already printed outermost call first
+func parsePython(lines []string) []Stack {
+	var stacks []Stack
+	current := Stack{Language: LANGUAGE_PYTHON}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if match := pythonFrame.FindStringSubmatch(line); match != nil {
+			number, _ := strconv.Atoi(match[2])
+			current.Frames = append(current.Frames, Frame{
+				File:     match[1],
+				Line:     number,
+				Function: match[3],
+				InApp:    pythonInApp(match[1]),
+			})
+			continue
+		}
+		if isPythonChain(trimmed) {
+			stacks = append(stacks, current)
+			current = Stack{Language: LANGUAGE_PYTHON}
+			continue
+		}
+		// The exception is the first unindented line after the frames, the indented ones are source and carets
+		if len(current.Frames) > 0 && current.Exception == "" && trimmed != "" && line == strings.TrimLeft(line, " \t") {
+			current.Exception, current.Message = splitException(trimmed)
+		}
+	}
+	return append(stacks, current)
+}
+
+func isPythonChain(line string) bool {
+	for _, chain := range pythonChain {
+		if line == chain {
+			return true
+		}
+	}
+	return false
+}
+
+func pythonInApp(file string) bool {
+	if strings.HasPrefix(file, "<") {
+		return false
+	}
+	for _, dir := range []string{"site-packages", "dist-packages", "/lib/python", "/lib64/python"} {
+		if strings.Contains(file, dir) {
+			return false
+		}
+	}
+	return true
+}
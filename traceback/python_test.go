@@ -0,0 +1,87 @@
+package traceback
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePython(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []Stack
+	}{
+		{
+			name: "during handling",
+			trace: `Traceback (most recent call last):
+  File "/app/orders/views.py", line 42, in checkout
+    total = compute_total(cart)
+            ^^^^^^^^^^^^^^^^^^^
+  File "/usr/lib/python3.11/site-packages/pricing/totals.py", line 7, in compute_total
+    return sum(item["price"] for item in cart)
+KeyError: 'price'
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/app/orders/views.py", line 45, in checkout
+    raise CheckoutError("cart is invalid")
+orders.errors.CheckoutError: cart is invalid`,
+			want: []Stack{
+				{Language: LANGUAGE_PYTHON, Exception: "KeyError", Message: "'price'", Frames: []Frame{
+					{File: "/app/orders/views.py", Line: 42, Function: "checkout", InApp: true},
+					{File: "/usr/lib/python3.11/site-packages/pricing/totals.py", Line: 7, Function: "compute_total", InApp: false},
+				}},
+				{Language: LANGUAGE_PYTHON, Exception: "orders.errors.CheckoutError", Message: "cart is invalid", Frames: []Frame{
+					{File: "/app/orders/views.py", Line: 45, Function: "checkout", InApp: true},
+				}},
+			},
+		},
+		{
+			name: "direct cause",
+			trace: `Traceback (most recent call last):
+  File "<frozen importlib._bootstrap>", line 1176, in _find_and_load
+ModuleNotFoundError: No module named 'redis'
+
+The above exception was the direct cause of the following exception:
+
+Traceback (most recent call last):
+  File "/srv/worker/main.py", line 3, in <module>
+    import cache
+ImportError: cache needs redis`,
+			want: []Stack{
+				{Language: LANGUAGE_PYTHON, Exception: "ModuleNotFoundError", Message: "No module named 'redis'", Frames: []Frame{
+					{File: "<frozen importlib._bootstrap>", Line: 1176, Function: "_find_and_load", InApp: false},
+				}},
+				{Language: LANGUAGE_PYTHON, Exception: "ImportError", Message: "cache needs redis", Frames: []Frame{
+					{File: "/srv/worker/main.py", Line: 3, Function: "<module>", InApp: true},
+				}},
+			},
+		},
+		{
+			name: "exception without a message",
+			trace: `Traceback (most recent call last):
+  File "/app/jobs.py", line 9, in run
+    next(queue)
+StopIteration`,
+			want: []Stack{
+				{Language: LANGUAGE_PYTHON, Exception: "StopIteration", Frames: []Frame{
+					{File: "/app/jobs.py", Line: 9, Function: "run", InApp: true},
+				}},
+			},
+		},
+		{
+			name:  "bare exception line",
+			trace: "ValueError: invalid literal for int() with base 10: 'x'",
+			want:  nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Parse(test.trace)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
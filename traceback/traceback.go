@@ -0,0 +1,91 @@
+package traceback
+
+import (
+	"strings"
+)
+
+const (
+	LANGUAGE_PYTHON = "python"
+	LANGUAGE_GO     = "go"
+	LANGUAGE_JAVA   = "java"
+	LANGUAGE_NODE   = "node"
+)
+
+// A single call in a stack trace. Fields the trace doesn't include are left empty.
+type Frame struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Function string `json:"function,omitempty"`
+	// False for frames in the language's standard library or third party packages
+	InApp bool `json:"in_app"`
+}
+
+// One exception or goroutine of a trace. Frames are ordered outermost call first, most recent call last,
+// whatever order the language prints them in.
+type Stack struct {
+	Language string `json:"language"`
+	// Exception type and message when the trace names one, eg ValueError, java.io.IOException or panic
+	Exception string `json:"exception,omitempty"`
+	Message   string `json:"message,omitempty"`
+	// Goroutine header of a Go dump, eg "goroutine 1 [running]"
+	Thread string  `json:"thread,omitempty"`
+	Frames []Frame `json:"frames"`
+}
+
+// Parses a traceback into its stacks: chained exceptions and each goroutine of a dump get their own.
+// Returns nil when the text isn't recognised as a trace in any supported language.
+func Parse(text string) []Stack {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	var stacks []Stack
+	switch detect(lines) {
+	case LANGUAGE_PYTHON:
+		stacks = parsePython(lines)
+	case LANGUAGE_GO:
+		stacks = parseGo(lines)
+	case LANGUAGE_JAVA:
+		stacks = parseJava(lines)
+	case LANGUAGE_NODE:
+		stacks = parseNode(lines)
+	}
+	// Stacks without frames are noise, such as a bare exception line
+	parsed := stacks[:0]
+	for _, stack := range stacks {
+		if len(stack.Frames) > 0 {
+			parsed = append(parsed, stack)
+		}
+	}
+	if len(parsed) == 0 {
+		return nil
+	}
+	return parsed
+}
+
+// Picks the language from the first line that looks like a frame in one of them
+func detect(lines []string) string {
+	for _, line := range lines {
+		switch {
+		case pythonFrame.MatchString(line):
+			return LANGUAGE_PYTHON
+		case goroutineHeader.MatchString(line), goLocation.MatchString(line):
+			return LANGUAGE_GO
+		case javaFrame.MatchString(line):
+			return LANGUAGE_JAVA
+		case nodeFrame.MatchString(line):
+			return LANGUAGE_NODE
+		}
+	}
+	return ""
+}
+
+func reverse(frames []Frame) {
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+}
+
+// Splits "Type: message" as printed by most runtimes
+func splitException(line string) (string, string) {
+	exception, message, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.TrimSpace(exception), strings.TrimSpace(message)
+}